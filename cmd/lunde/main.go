@@ -7,32 +7,60 @@ import (
 	"os/signal"
 	"syscall"
 
+	// embed the timezone database, as the docker image does not have one
+	_ "time/tzdata"
+
 	"github.com/diamondburned/arikawa/v3/gateway"
 	"github.com/diamondburned/arikawa/v3/session"
+	"github.com/polarbirds/lunde/internal/autorespond"
 	"github.com/polarbirds/lunde/internal/channelnames"
+	"github.com/polarbirds/lunde/internal/command/autoreact"
 	"github.com/polarbirds/lunde/internal/command/count"
 	"github.com/polarbirds/lunde/internal/command/define"
+	"github.com/polarbirds/lunde/internal/command/jobs"
 	"github.com/polarbirds/lunde/internal/command/members"
 	"github.com/polarbirds/lunde/internal/command/promote"
+	"github.com/polarbirds/lunde/internal/command/reactionrole"
+	"github.com/polarbirds/lunde/internal/command/reactions"
 	"github.com/polarbirds/lunde/internal/command/reddit"
+	"github.com/polarbirds/lunde/internal/command/remind"
 	"github.com/polarbirds/lunde/internal/command/roles"
+	"github.com/polarbirds/lunde/internal/command/schedule"
 	"github.com/polarbirds/lunde/internal/command/slap"
 	"github.com/polarbirds/lunde/internal/command/text"
+	"github.com/polarbirds/lunde/internal/digest"
 	"github.com/polarbirds/lunde/internal/healthcheck"
+	"github.com/polarbirds/lunde/internal/pinvote"
 	"github.com/polarbirds/lunde/internal/server"
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	createDefineCommand, createGlossaryCommand  = define.CreateCommands()
+	createRedditCommand, createSubredditCommand = reddit.CreateCommands()
+	createRemindCommand, createRemindersCommand = remind.CreateCommands()
+)
+
 // commandCreators is the list of handlers of the commands that are active
 var commandCreators = []server.CreateCommand{
-	reddit.CreateCommand,
+	createRedditCommand,
+	createSubredditCommand,
 	slap.CreateCommand,
-	define.CreateCommand,
+	createDefineCommand,
+	createGlossaryCommand,
 	text.CreateCommand,
 	promote.CreateCommand,
 	roles.CreateCommand,
 	members.CreateCommand,
 	count.CreateCommand,
+	reactionrole.CreateCommand,
+	autoreact.CreateCommand,
+	reactions.CreateCommand,
+	channelnames.CreateCommand,
+	jobs.CreateCommand,
+	createRemindCommand,
+	createRemindersCommand,
+	schedule.CreateCommand,
 }
 
 func main() {
@@ -49,6 +77,9 @@ func main() {
 	sess.AddHandler(srv.HandleMessageCreate)
 	sess.AddHandler(srv.HandleInteraction)
 	sess.AddHandler(srv.HandleReactionAddInteraction)
+	sess.AddHandler(srv.HandleReactionRemoveInteraction)
+	sess.AddHandler(srv.HandleReactionRemoveAllInteraction)
+	sess.AddHandler(srv.HandleReactionRemoveEmojiInteraction)
 
 	sess.AddIntents(gateway.IntentGuilds)
 	sess.AddIntents(gateway.IntentGuildMessages)
@@ -90,11 +121,20 @@ func main() {
 
 	go healthcheck.StartHandlerIfEnabled()
 
-	err = channelnames.StartScheduler(&srv)
+	err = autorespond.Register(&srv)
+	if err != nil {
+		logrus.Fatalf("error registering autoresponses: %v", err)
+	}
+
+	err = pinvote.Register(&srv)
 	if err != nil {
-		logrus.Fatalf("error starting channelnames scheduler: %v", err)
+		logrus.Fatalf("error registering pin voting: %v", err)
+	}
+
+	err = digest.Register(&srv)
+	if err != nil {
+		logrus.Fatalf("error registering weekly digest: %v", err)
 	}
-	logrus.Info("channelnames scheduler started")
 
 	logrus.Info("Bot is now running.  Press CTRL-C to exit.")
 	sc := make(chan os.Signal, 1)
@@ -102,4 +142,11 @@ func main() {
 	sigRec := <-sc
 
 	logrus.Infof("signal %v received, exiting...", sigRec)
+	srv.Scheduler.Stop()
+
+	// reactions since the last periodic save would otherwise be lost
+	err = srv.SaveReactionData()
+	if err != nil {
+		logrus.Errorf("error occurred saving reaction data: %v", err)
+	}
 }
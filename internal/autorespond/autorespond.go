@@ -0,0 +1,248 @@
+package autorespond
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"text/template"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/haraldfw/cfger"
+	"github.com/polarbirds/lunde/internal/server"
+	"github.com/polarbirds/lunde/internal/tmplfuncs"
+	"github.com/polarbirds/lunde/internal/trigger"
+	"github.com/sirupsen/logrus"
+)
+
+type autoRespondConfig struct {
+	AutoResponses []*response `yaml:"autoResponses"`
+}
+
+// response replies to messages firing the trigger with Text, a random pick of Choices, or Embed.
+// All texts are Go templates executed with templateData
+type response struct {
+	Name            string         `yaml:"name"`
+	Text            string         `yaml:"text"`
+	Choices         []string       `yaml:"choices"`
+	Embed           *embedTemplate `yaml:"embed"`
+	trigger.Trigger `yaml:",inline"`
+
+	text    *template.Template
+	choices []*template.Template
+}
+
+type embedTemplate struct {
+	Title       string        `yaml:"title"`
+	Description string        `yaml:"description"`
+	URL         string        `yaml:"url"`
+	Image       string        `yaml:"image"`
+	Color       discord.Color `yaml:"color"`
+
+	title       *template.Template
+	description *template.Template
+}
+
+// templateData is what response templates have access to. Captures holds the whole match followed
+// by the capture groups of the pattern, or only the matched word
+type templateData struct {
+	Author   person
+	Channel  place
+	Content  string
+	Captures []string
+}
+
+type person struct {
+	ID      discord.UserID
+	Name    string
+	Mention string
+}
+
+type place struct {
+	ID      discord.ChannelID
+	Name    string
+	Mention string
+}
+
+type autoResponder struct {
+	srv       *server.Server
+	responses []*response
+}
+
+// Register reads the autoResponses section of the config and starts replying to the messages
+// matching them
+func Register(srv *server.Server) (err error) {
+	var cfg autoRespondConfig
+	_, err = cfger.ReadStructuredCfgRecursive("env::CONFIG", &cfg)
+	if err != nil {
+		err = fmt.Errorf("reading autorespond config: %v", err)
+		return
+	}
+
+	ar := autoResponder{srv: srv}
+	for _, r := range cfg.AutoResponses {
+		err = r.compile()
+		if err != nil {
+			err = fmt.Errorf("autoresponse %q: %v", r.Name, err)
+			return
+		}
+		ar.responses = append(ar.responses, r)
+	}
+
+	srv.AddMessageCreateHandler(ar.handleMessageCreate)
+	logrus.Infof("registered %d autoresponses", len(ar.responses))
+
+	return
+}
+
+func (r *response) compile() (err error) {
+	if r.Text == "" && len(r.Choices) == 0 && r.Embed == nil {
+		return errors.New("one of text, choices or embed must be given")
+	}
+
+	if r.Text != "" {
+		r.text, err = parseTemplate(r.Text)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, choice := range r.Choices {
+		var tmpl *template.Template
+		tmpl, err = parseTemplate(choice)
+		if err != nil {
+			return
+		}
+		r.choices = append(r.choices, tmpl)
+	}
+
+	if r.Embed != nil {
+		r.Embed.title, err = parseTemplate(r.Embed.Title)
+		if err != nil {
+			return
+		}
+		r.Embed.description, err = parseTemplate(r.Embed.Description)
+		if err != nil {
+			return
+		}
+	}
+
+	return r.Compile()
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("").Funcs(tmplfuncs.Funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %q: %v", text, err)
+	}
+	return tmpl, nil
+}
+
+func execute(tmpl *template.Template, data templateData) (string, error) {
+	buf := bytes.Buffer{}
+	err := tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", fmt.Errorf("executing template: %v", err)
+	}
+	return buf.String(), nil
+}
+
+func (ar *autoResponder) handleMessageCreate(ev *gateway.MessageCreateEvent) {
+	if ev.Author.Bot {
+		return
+	}
+
+	for _, r := range ar.responses {
+		captures, fired := r.Fire(ev.ChannelID, ev.Content)
+		if !fired {
+			continue
+		}
+
+		err := ar.respond(ev, r, captures)
+		if err != nil {
+			logrus.Errorf("error occurred sending autoresponse %q: %v", r.Name, err)
+		}
+	}
+}
+
+func (ar *autoResponder) respond(
+	ev *gateway.MessageCreateEvent, r *response, captures []string,
+) error {
+	data, err := ar.templateData(ev, captures)
+	if err != nil {
+		return err
+	}
+
+	msg := api.SendMessageData{
+		Reference: &discord.MessageReference{MessageID: ev.ID},
+		AllowedMentions: &api.AllowedMentions{
+			Parse: []api.AllowedMentionType{api.AllowUserMention},
+		},
+	}
+
+	tmpl := r.text
+	if len(r.choices) > 0 {
+		tmpl = r.choices[rand.Intn(len(r.choices))]
+	}
+	if tmpl != nil {
+		msg.Content, err = execute(tmpl, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.Embed != nil {
+		embed := discord.Embed{
+			URL:   r.Embed.URL,
+			Color: r.Embed.Color,
+		}
+		if r.Embed.Image != "" {
+			embed.Image = &discord.EmbedImage{URL: r.Embed.Image}
+		}
+		embed.Title, err = execute(r.Embed.title, data)
+		if err != nil {
+			return err
+		}
+		embed.Description, err = execute(r.Embed.description, data)
+		if err != nil {
+			return err
+		}
+		msg.Embeds = []discord.Embed{embed}
+	}
+
+	_, err = ar.srv.Session.SendMessageComplex(ev.ChannelID, msg)
+	return err
+}
+
+func (ar *autoResponder) templateData(ev *gateway.MessageCreateEvent, captures []string) (
+	data templateData, err error,
+) {
+	ch, err := ar.srv.Session.Channel(ev.ChannelID)
+	if err != nil {
+		err = fmt.Errorf("getting channel: %v", err)
+		return
+	}
+
+	name := ev.Author.Username
+	if ev.Member != nil && ev.Member.Nick != "" {
+		name = ev.Member.Nick
+	}
+
+	data = templateData{
+		Author: person{
+			ID:      ev.Author.ID,
+			Name:    name,
+			Mention: ev.Author.Mention(),
+		},
+		Channel: place{
+			ID:      ch.ID,
+			Name:    ch.Name,
+			Mention: ch.Mention(),
+		},
+		Content:  ev.Content,
+		Captures: captures,
+	}
+	return
+}
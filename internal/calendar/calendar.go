@@ -0,0 +1,87 @@
+package calendar
+
+import (
+	"time"
+)
+
+// Holiday is a public holiday on the date given by Year, Month and Day
+type Holiday struct {
+	Name  string
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// NorwegianHolidays returns the Norwegian public holidays of the given year, including the ones
+// relative to easter
+func NorwegianHolidays(year int) []Holiday {
+	easter := Easter(year)
+	relative := func(name string, days int) Holiday {
+		date := easter.AddDate(0, 0, days)
+		return Holiday{Name: name, Year: year, Month: date.Month(), Day: date.Day()}
+	}
+
+	return []Holiday{
+		{Name: "Nyttårsdag", Year: year, Month: time.January, Day: 1},
+		relative("Skjærtorsdag", -3),
+		relative("Langfredag", -2),
+		relative("Første påskedag", 0),
+		relative("Andre påskedag", 1),
+		{Name: "Arbeidernes dag", Year: year, Month: time.May, Day: 1},
+		{Name: "Grunnlovsdag", Year: year, Month: time.May, Day: 17},
+		relative("Kristi himmelfartsdag", 39),
+		relative("Første pinsedag", 49),
+		relative("Andre pinsedag", 50),
+		{Name: "Første juledag", Year: year, Month: time.December, Day: 25},
+		{Name: "Andre juledag", Year: year, Month: time.December, Day: 26},
+	}
+}
+
+// Easter returns the date of easter sunday in the given year, in UTC, computed with the anonymous
+// gregorian algorithm
+func Easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// NorwegianHoliday returns the Norwegian public holiday on the date of t in its location, if any
+func NorwegianHoliday(t time.Time) (holiday Holiday, isHoliday bool) {
+	for _, h := range NorwegianHolidays(t.Year()) {
+		if h.Month == t.Month() && h.Day == t.Day() {
+			return h, true
+		}
+	}
+	return
+}
+
+// IsDayOff returns whether the date of t is a weekend or a Norwegian public holiday
+func IsDayOff(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return true
+	}
+
+	_, isHoliday := NorwegianHoliday(t)
+	return isHoliday
+}
+
+// DaysBetween returns the number of calendar days from the date of from to the date of to, each in
+// their own location
+func DaysBetween(from, to time.Time) int {
+	fromDate := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDate := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDate.Sub(fromDate).Hours() / 24)
+}
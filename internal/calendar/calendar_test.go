@@ -0,0 +1,113 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEaster(t *testing.T) {
+	tests := []struct {
+		year  int
+		month time.Month
+		day   int
+	}{
+		{1961, time.April, 2},
+		{2000, time.April, 23},
+		{2008, time.March, 23},
+		{2019, time.April, 21},
+		{2024, time.March, 31},
+		{2025, time.April, 20},
+		{2026, time.April, 5},
+		{2038, time.April, 25},
+	}
+
+	for _, tt := range tests {
+		got := Easter(tt.year)
+		if got.Month() != tt.month || got.Day() != tt.day {
+			t.Errorf("Easter(%d) = %s, want %s %d", tt.year, got.Format("January 2"), tt.month,
+				tt.day)
+		}
+	}
+}
+
+func TestNorwegianHolidays(t *testing.T) {
+	want := map[string]string{
+		"Nyttårsdag":            "01-01",
+		"Skjærtorsdag":          "03-28",
+		"Langfredag":            "03-29",
+		"Første påskedag":       "03-31",
+		"Andre påskedag":        "04-01",
+		"Arbeidernes dag":       "05-01",
+		"Grunnlovsdag":          "05-17",
+		"Kristi himmelfartsdag": "05-09",
+		"Første pinsedag":       "05-19",
+		"Andre pinsedag":        "05-20",
+		"Første juledag":        "12-25",
+		"Andre juledag":         "12-26",
+	}
+
+	holidays := NorwegianHolidays(2024)
+	if len(holidays) != len(want) {
+		t.Fatalf("got %d holidays, want %d", len(holidays), len(want))
+	}
+	for _, h := range holidays {
+		got := time.Date(h.Year, h.Month, h.Day, 0, 0, 0, 0, time.UTC).Format("01-02")
+		if h.Year != 2024 || got != want[h.Name] {
+			t.Errorf("got %s on %d-%s, want 2024-%s", h.Name, h.Year, got, want[h.Name])
+		}
+	}
+}
+
+func TestIsDayOff(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		date string
+		want bool
+	}{
+		{"2024-03-27", false}, // wednesday before easter
+		{"2024-03-28", true},  // skjærtorsdag
+		{"2024-03-30", true},  // saturday
+		{"2024-04-02", false}, // tuesday after easter
+		{"2024-05-17", true},  // grunnlovsdag
+		{"2024-12-24", false}, // christmas eve is not a public holiday
+	}
+
+	for _, tt := range tests {
+		date, err := time.ParseInLocation("2006-01-02", tt.date, oslo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := IsDayOff(date); got != tt.want {
+			t.Errorf("IsDayOff(%s) = %t, want %t", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestDaysBetween(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from time.Time
+		to   time.Time
+		want int
+	}{
+		// across the spring DST change, which has a 23 hour day
+		{time.Date(2024, 3, 30, 23, 0, 0, 0, oslo), time.Date(2024, 4, 1, 0, 30, 0, 0, oslo), 2},
+		{time.Date(2024, 12, 31, 23, 59, 0, 0, oslo), time.Date(2025, 1, 1, 0, 0, 0, 0, oslo), 1},
+		{time.Date(2024, 5, 17, 12, 0, 0, 0, oslo), time.Date(2024, 5, 17, 1, 0, 0, 0, oslo), 0},
+		{time.Date(2025, 1, 1, 0, 0, 0, 0, oslo), time.Date(2024, 12, 25, 0, 0, 0, 0, oslo), -7},
+	}
+
+	for _, tt := range tests {
+		if got := DaysBetween(tt.from, tt.to); got != tt.want {
+			t.Errorf("DaysBetween(%s, %s) = %d, want %d", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,17 @@
 package channelnames
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/haraldfw/cfger"
+	"github.com/polarbirds/lunde/internal/scheduler"
 	"github.com/polarbirds/lunde/internal/server"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/robfig/cron.v2"
@@ -15,104 +20,459 @@ import (
 const fridayAt16CronPattern = "0 16 * * 5"
 const mondayAt01CronPattern = "0 1 * * 1"
 
+const storeName = "channelnames"
+
+// defaultSchedules are used when the config has no channelNameSchedules section
+var defaultSchedules = []schedule{
+	{
+		Name:         "beer",
+		Cron:         fridayAt16CronPattern,
+		RevertCron:   mondayAt01CronPattern,
+		Replacements: []replacement{{From: "☕", To: "🍻"}},
+	},
+	{
+		Name:         "nm",
+		Cron:         mondayAt01CronPattern,
+		Replacements: []replacement{{From: "n", To: "m"}, {From: "N", To: "M"}},
+		Probability:  0.1,
+	},
+}
+
+type channelNamesConfig struct {
+	ChannelNameSchedules []schedule    `yaml:"channelNameSchedules"`
+	LiveChannels         []liveChannel `yaml:"liveChannels"`
+}
+
+// schedule replaces strings in the names of channels, and themes roles, the guild icon and the
+// nickname of the bot, at the times given by Cron. Everything is restored at the times given by
+// RevertCron
+type schedule struct {
+	Name         string        `yaml:"name"`
+	Cron         string        `yaml:"cron"`
+	RevertCron   string        `yaml:"revertCron"`
+	Replacements []replacement `yaml:"replacements"`
+	// Probability is the chance of the replacements being done each time, 0 means always
+	Probability float64 `yaml:"probability"`
+	// Channels and Categories limit the replacements to the given channels and channels in the
+	// given categories. All channels are affected if both are empty
+	Channels   []discord.ChannelID `yaml:"channels"`
+	Categories []discord.ChannelID `yaml:"categories"`
+	Disabled   bool                `yaml:"disabled"`
+	// Timezone is the IANA timezone Cron is evaluated in, defaults to the timezone of the server
+	Timezone string `yaml:"timezone"`
+	// Holidays makes Cron also run around Norwegian public holidays, see holidaysEve and
+	// holidaysAfter. RevertHolidays does the same for RevertCron
+	Holidays       string `yaml:"holidays"`
+	RevertHolidays string `yaml:"revertHolidays"`
+	// Roles, GuildIcon and Nickname theme the guild while the schedule is applied. GuildIcon is a
+	// URL or path to a png, jpeg or gif
+	Roles     []roleTheme `yaml:"roles"`
+	GuildIcon string      `yaml:"guildIcon"`
+	Nickname  string      `yaml:"nickname"`
+}
+
+type replacement struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// snapshot holds what a schedule changed, so it can be reverted
+type snapshot struct {
+	// Applied is when the schedule was applied, ordering the schedules renaming the same channel
+	Applied time.Time `json:"applied"`
+	// Channels holds the names of the renamed channels, by channel
+	Channels  map[discord.ChannelID]renamed `json:"channels"`
+	Roles     map[discord.RoleID]themedRole `json:"roles,omitempty"`
+	GuildIcon *themedIcon                   `json:"guildIcon,omitempty"`
+	Nickname  *renamed                      `json:"nickname,omitempty"`
+}
+
+type renamed struct {
+	Original string `json:"original"`
+	Renamed  string `json:"renamed"`
+}
+
 type channelNamesRunner struct {
-	srv *server.Server
+	srv       *server.Server
+	queue     *renameQueue
+	schedules []schedule
+	live      []liveChannel
+	// snapshots are the snapshots of the schedules currently applied, by schedule name
+	snapshots map[string]*snapshot
+	mutex     sync.Mutex
 }
 
-// StartScheduler starts the monday/friday string replacer
-func StartScheduler(srv *server.Server) (err error) {
-	wnr := channelNamesRunner{srv}
+func newRunner(srv *server.Server) (wnr *channelNamesRunner, err error) {
+	wnr = &channelNamesRunner{
+		srv:       srv,
+		queue:     newRenameQueue(srv.Session),
+		snapshots: map[string]*snapshot{},
+	}
 
-	cron := cron.New()
-	_, err = cron.AddFunc(fridayAt16CronPattern, wnr.createChannelNamesReplacer("☕", "🍻"))
+	var cfg channelNamesConfig
+	_, err = cfger.ReadStructuredCfgRecursive("env::CONFIG", &cfg)
 	if err != nil {
-		err = fmt.Errorf("create replacer for fridays: %v", err)
+		err = fmt.Errorf("reading channelnames config: %v", err)
 		return
 	}
 
-	_, err = cron.AddFunc(mondayAt01CronPattern, wnr.createChannelNamesReplacer("🍻", "☕"))
+	wnr.schedules = cfg.ChannelNameSchedules
+	if wnr.schedules == nil {
+		wnr.schedules = defaultSchedules
+	}
+	wnr.live = cfg.LiveChannels
+
+	err = srv.Store.Load(storeName, &wnr.snapshots)
 	if err != nil {
-		err = fmt.Errorf("create replacer for mondays: %v", err)
+		err = fmt.Errorf("loading channel name snapshots: %v", err)
 		return
 	}
 
-	_, err = cron.AddFunc(mondayAt01CronPattern, wnr.channelnameReplaceNM)
+	return
+}
+
+// registerJobs registers jobs applying and reverting the schedules of the runner with the
+// scheduler of the server
+func (wnr *channelNamesRunner) registerJobs() (err error) {
+	for _, sch := range wnr.schedules {
+		if sch.Disabled {
+			logrus.Infof("channel name schedule %q is disabled", sch.Name)
+			continue
+		}
+
+		err = sch.validate()
+		if err != nil {
+			err = fmt.Errorf("channel name schedule %q: %v", sch.Name, err)
+			return
+		}
+
+		var sched cron.Schedule
+		sched, err = sch.parse(sch.Cron, sch.Holidays, wnr.srv.Location)
+		if err != nil {
+			err = fmt.Errorf("create replacer for %q: %v", sch.Name, err)
+			return
+		}
+
+		err = wnr.srv.Scheduler.Add(scheduler.Job{
+			Name:        "channelnames-" + sch.Name,
+			Description: fmt.Sprintf("apply channel name schedule %q", sch.Name),
+			Schedule:    sched,
+			Run:         wnr.createChannelNamesReplacer(sch),
+			CatchUp:     true,
+		})
+		if err != nil {
+			return
+		}
+
+		if sch.RevertCron == "" {
+			continue
+		}
+
+		sched, err = sch.parse(sch.RevertCron, sch.RevertHolidays, wnr.srv.Location)
+		if err != nil {
+			err = fmt.Errorf("create reverter for %q: %v", sch.Name, err)
+			return
+		}
+
+		err = wnr.srv.Scheduler.Add(scheduler.Job{
+			Name:        "channelnames-" + sch.Name + "-revert",
+			Description: fmt.Sprintf("revert channel name schedule %q", sch.Name),
+			Schedule:    sched,
+			Run:         wnr.createChannelNamesReverter(sch),
+			CatchUp:     true,
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (sch *schedule) validate() error {
+	if len(sch.Replacements) == 0 && !sch.hasTheme() {
+		return errors.New("no replacements, roles, guild icon or nickname given")
+	}
+
+	for _, r := range sch.Replacements {
+		if r.From == "" {
+			return errors.New("replacement has nothing to replace from")
+		}
+	}
+
+	if sch.Probability < 0 || sch.Probability > 1 {
+		return fmt.Errorf("probability %v is not between 0 and 1", sch.Probability)
+	}
+
+	return nil
+}
+
+// parse parses the given cron expression in the timezone of the schedule, or the given location if
+// the schedule has none
+func (sch *schedule) parse(spec string, holidays string, loc *time.Location) (
+	sched cron.Schedule, err error,
+) {
+	tz := sch.Timezone
+	if tz == "" {
+		tz = loc.String()
+	}
+
+	sched, err = cron.Parse(fmt.Sprintf("TZ=%s %s", tz, spec))
 	if err != nil {
-		err = fmt.Errorf("create replacer for mondays: %v", err)
 		return
 	}
 
-	cron.Start()
+	if holidays != "" {
+		sched, err = newHolidaySchedule(sched, holidays)
+	}
+	return
+}
+
+// includes returns whether the channel is affected by the schedule
+func (sch *schedule) includes(ch discord.Channel) bool {
+	if len(sch.Channels) == 0 && len(sch.Categories) == 0 {
+		return true
+	}
+
+	for _, id := range sch.Channels {
+		if id == ch.ID {
+			return true
+		}
+	}
+
+	for _, id := range sch.Categories {
+		if id == ch.ParentID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (sch *schedule) replace(name string) string {
+	for _, r := range sch.Replacements {
+		name = strings.ReplaceAll(name, r.From, r.To)
+	}
+	return name
+}
+
+func (wnr *channelNamesRunner) findSchedule(name string) (sch schedule, err error) {
+	for _, sch = range wnr.schedules {
+		if sch.Name == name {
+			return
+		}
+	}
 
+	err = fmt.Errorf("found no channel name schedule named %q", name)
 	return
 }
 
-func (wnr *channelNamesRunner) createChannelNamesReplacer(from string, to string) func() {
-	return func() {
-		err := wnr.replaceChannelNames(from, to)
+func (wnr *channelNamesRunner) createChannelNamesReplacer(sch schedule) func() error {
+	return func() error {
+		if sch.Probability != 0 && rand.Float64() >= sch.Probability {
+			return nil
+		}
+
+		err := wnr.apply(sch)
 		if err != nil {
-			logrus.Errorf("error occurred replacing %q with %q in channel names: %v", from, to, err)
-			return
+			return fmt.Errorf("applying channel name schedule %q: %v", sch.Name, err)
 		}
-		logrus.Infof("replaced character %q with %q in channel names", from, to)
+		logrus.Infof("applied channel name schedule %q", sch.Name)
+		return nil
 	}
 }
 
-func (wnr *channelNamesRunner) channelnameReplaceNM() {
-	if rand.Float32() > 0.1 {
-		return
+func (wnr *channelNamesRunner) createChannelNamesReverter(sch schedule) func() error {
+	return func() error {
+		err := wnr.revert(sch)
+		if err != nil {
+			return fmt.Errorf("reverting channel name schedule %q: %v", sch.Name, err)
+		}
+		logrus.Infof("reverted channel name schedule %q", sch.Name)
+		return nil
 	}
-	err := wnr.replaceChannelNames("n", "m")
+}
+
+// preview returns the channels the schedule would rename if applied now, and their new names
+func (wnr *channelNamesRunner) preview(sch schedule) (
+	chans []discord.Channel, names []string, err error,
+) {
+	all, err := wnr.srv.Session.Channels(wnr.srv.GuildID)
 	if err != nil {
-		logrus.Errorf("error occurred replacing n with m in channel names: %v", err)
+		err = fmt.Errorf("getting channel names; %v", err)
 		return
 	}
-	err = wnr.replaceChannelNames("N", "M")
+
+	for _, ch := range all {
+		if !sch.includes(ch) {
+			continue
+		}
+
+		// names of channels with queued renames are replaced as they will be when renamed
+		ch.Name = wnr.queue.name(ch.ID, ch.Name)
+		name := sch.replace(ch.Name)
+		if name == ch.Name {
+			continue
+		}
+
+		chans = append(chans, ch)
+		names = append(names, name)
+	}
+
+	return
+}
+
+// apply queues renaming the channels affected by the schedule and themes the guild, snapshotting
+// what it was like before
+func (wnr *channelNamesRunner) apply(sch schedule) error {
+	wnr.mutex.Lock()
+	defer wnr.mutex.Unlock()
+
+	chans, names, err := wnr.preview(sch)
 	if err != nil {
-		logrus.Errorf("error occurred replacing N with M in channel names: %v", err)
-		return
+		return err
+	}
+
+	snap, exists := wnr.snapshots[sch.Name]
+	if !exists {
+		snap = &snapshot{Applied: time.Now(), Channels: map[discord.ChannelID]renamed{}}
+		wnr.snapshots[sch.Name] = snap
+	}
+
+	for i, ch := range chans {
+		wnr.queue.enqueue(ch.ID, names[i])
+
+		// keep the name from before the schedule was first applied, if applied repeatedly
+		original := ch.Name
+		if previous, exists := snap.Channels[ch.ID]; exists {
+			original = previous.Original
+		}
+		snap.Channels[ch.ID] = renamed{Original: original, Renamed: names[i]}
 	}
-	logrus.Infof("replaced character n/N with m/M in channel names")
+
+	// the snapshot is saved even if theming partly fails, so what succeeded is reverted
+	themeErr := wnr.applyTheme(sch, snap)
+
+	err = wnr.srv.Store.Save(storeName, wnr.snapshots)
+	if err != nil {
+		return fmt.Errorf("saving snapshot: %v", err)
+	}
+
+	return themeErr
 }
 
-func (wnr *channelNamesRunner) replaceChannelNames(from string, to string) error {
+// revert queues restoring the names of the channels renamed by the schedule and restores the theme
+// of the guild. Channels also renamed by schedules applied later keep the changes of those, and
+// channels renamed by someone else since, or that failed to change, are left alone
+func (wnr *channelNamesRunner) revert(sch schedule) error {
+	wnr.mutex.Lock()
+	defer wnr.mutex.Unlock()
+
+	snap, exists := wnr.snapshots[sch.Name]
+	if !exists {
+		return nil
+	}
+
 	chans, err := wnr.srv.Session.Channels(wnr.srv.GuildID)
 	if err != nil {
 		return fmt.Errorf("getting channel names; %v", err)
 	}
 
-	errs := []error{}
+	byID := make(map[discord.ChannelID]discord.Channel, len(chans))
 	for _, ch := range chans {
-		err = wnr.replaceChannelName(ch, from, to)
-		if err != nil {
-			logrus.Errorf("error occurred replacing %q with %q in channel %q (ID is %d): %v",
-				from, to, ch.Name, ch.ID, err)
-			errs = append(errs, err)
+		byID[ch.ID] = ch
+	}
+
+	for chID, names := range snap.Channels {
+		layers := wnr.layers(chID)
+		ch, exists := byID[chID]
+		if !exists || wnr.queue.name(chID, ch.Name) != layers[len(layers)-1].names.Renamed {
+			logrus.Infof("not reverting channel %d to %q as it was renamed or deleted since",
+				chID, names.Original)
+			continue
+		}
+
+		name, updated, ok := composeRevert(layers, sch.Name)
+		if !ok {
+			logrus.Infof("not reverting channel %d to %q as it was renamed since", chID,
+				names.Original)
 			continue
 		}
+
+		for _, l := range updated {
+			wnr.snapshots[l.schedule].Channels[chID] = l.names
+		}
+		wnr.queue.enqueue(chID, name)
 	}
-	if len(errs) > 0 {
-		return fmt.Errorf("errors occurred updating channels: %v", errs)
+
+	themeErr := wnr.revertTheme(snap)
+	delete(wnr.snapshots, sch.Name)
+
+	err = wnr.srv.Store.Save(storeName, wnr.snapshots)
+	if err != nil {
+		return fmt.Errorf("saving snapshot: %v", err)
 	}
-	return nil
+
+	return themeErr
 }
 
-func (wnr *channelNamesRunner) replaceChannelName(
-	ch discord.Channel,
-	from string,
-	to string,
-) error {
-	if !strings.Contains(ch.Name, from) {
-		return nil
+// layer is the renaming of a channel by an applied schedule
+type layer struct {
+	schedule string
+	names    renamed
+	replace  func(string) string
+}
+
+// layers returns the renamings of the channel by the applied schedules, in the order they were
+// applied. It must be called with the mutex held
+func (wnr *channelNamesRunner) layers(chID discord.ChannelID) []layer {
+	layers := []layer{}
+	for name, snap := range wnr.snapshots {
+		names, exists := snap.Channels[chID]
+		if !exists {
+			continue
+		}
+
+		l := layer{schedule: name, names: names, replace: func(s string) string { return s }}
+		if sch, err := wnr.findSchedule(name); err == nil {
+			l.replace = sch.replace
+		}
+		layers = append(layers, l)
 	}
 
-	err := wnr.srv.Session.ModifyChannel(ch.ID, api.ModifyChannelData{
-		Name: strings.ReplaceAll(ch.Name, from, to),
+	sort.Slice(layers, func(i, j int) bool {
+		applied := func(l layer) time.Time { return wnr.snapshots[l.schedule].Applied }
+		if !applied(layers[i]).Equal(applied(layers[j])) {
+			return applied(layers[i]).Before(applied(layers[j]))
+		}
+		return layers[i].schedule < layers[j].schedule
 	})
-	if err != nil {
-		return fmt.Errorf("error occurred updating channel by name %q and ID %s: %v",
-			ch.Name, ch.ID, err)
+	return layers
+}
+
+// composeRevert returns the name of a channel with the layer of the reverted schedule removed,
+// redoing the replacements of the layers applied after it, and those layers with their updated
+// names. Only the layers renaming on top of each other up to the current name are considered, so
+// ok is false if the reverted layer was renamed over by someone else
+func composeRevert(layers []layer, reverted string) (name string, updated []layer, ok bool) {
+	start := len(layers) - 1
+	for start > 0 && layers[start-1].names.Renamed == layers[start].names.Original {
+		start--
 	}
 
-	return nil
+	name = layers[start].names.Original
+	for _, l := range layers[start:] {
+		switch {
+		case l.schedule == reverted:
+			ok = true
+		case !ok:
+			name = l.names.Renamed
+		default:
+			l.names.Original = name
+			name = l.replace(name)
+			l.names.Renamed = name
+			updated = append(updated, l)
+		}
+	}
+	return
 }
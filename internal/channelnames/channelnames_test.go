@@ -0,0 +1,86 @@
+package channelnames
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComposeRevert(t *testing.T) {
+	beer := schedule{Replacements: []replacement{{From: "☕", To: "🍻"}}}
+	nm := schedule{Replacements: []replacement{{From: "n", To: "m"}}}
+
+	tests := []struct {
+		name        string
+		layers      []layer
+		reverted    string
+		want        string
+		wantUpdated []renamed
+		wantOK      bool
+	}{
+		{
+			name: "only layer",
+			layers: []layer{
+				{schedule: "beer", names: renamed{"☕-lunsj", "🍻-lunsj"}, replace: beer.replace},
+			},
+			reverted: "beer",
+			want:     "☕-lunsj",
+			wantOK:   true,
+		},
+		{
+			name: "later layer is redone",
+			layers: []layer{
+				{schedule: "beer", names: renamed{"☕-lunsj", "🍻-lunsj"}, replace: beer.replace},
+				{schedule: "nm", names: renamed{"🍻-lunsj", "🍻-lumsj"}, replace: nm.replace},
+			},
+			reverted:    "beer",
+			want:        "☕-lumsj",
+			wantUpdated: []renamed{{"☕-lunsj", "☕-lumsj"}},
+			wantOK:      true,
+		},
+		{
+			name: "earlier layer is kept",
+			layers: []layer{
+				{schedule: "nm", names: renamed{"☕-lunsj", "☕-lumsj"}, replace: nm.replace},
+				{schedule: "beer", names: renamed{"☕-lumsj", "🍻-lumsj"}, replace: beer.replace},
+			},
+			reverted: "beer",
+			want:     "☕-lumsj",
+			wantOK:   true,
+		},
+		{
+			name: "renamed over by someone else",
+			layers: []layer{
+				{schedule: "beer", names: renamed{"☕-lunsj", "🍻-lunsj"}, replace: beer.replace},
+				{schedule: "nm", names: renamed{"🍻-food", "🍻-food"}, replace: nm.replace},
+			},
+			reverted: "beer",
+		},
+	}
+
+	for _, tt := range tests {
+		name, updated, ok := composeRevert(tt.layers, tt.reverted)
+		if ok != tt.wantOK {
+			t.Errorf("%s: got ok %t, want %t", tt.name, ok, tt.wantOK)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if name != tt.want {
+			t.Errorf("%s: got name %q, want %q", tt.name, name, tt.want)
+		}
+
+		got := []string{}
+		for _, l := range updated {
+			got = append(got, l.names.Original+" -> "+l.names.Renamed)
+		}
+		want := []string{}
+		for _, names := range tt.wantUpdated {
+			want = append(want, names.Original+" -> "+names.Renamed)
+		}
+		if strings.Join(got, ", ") != strings.Join(want, ", ") {
+			t.Errorf("%s: got updated layers %v, want %v", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package channelnames
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/polarbirds/lunde/internal/command"
+	"github.com/polarbirds/lunde/internal/server"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateCommand schedules the channel name schedules and live channels from the config, and creates
+// a lunde command to preview, apply and revert the schedules manually
+func CreateCommand(srv *server.Server) (cmd command.LundeCommand, err error) {
+	wnr, err := newRunner(srv)
+	if err != nil {
+		return
+	}
+
+	err = wnr.registerJobs()
+	if err != nil {
+		err = fmt.Errorf("registering channelnames jobs: %v", err)
+		return
+	}
+
+	err = wnr.registerLiveChannels()
+	if err != nil {
+		err = fmt.Errorf("registering live channels: %v", err)
+		return
+	}
+
+	choices := []discord.StringChoice{}
+	for _, sch := range wnr.schedules {
+		choices = append(choices, discord.StringChoice{Name: sch.Name, Value: sch.Name})
+	}
+
+	scheduleOption := func() []discord.CommandOptionValue {
+		return []discord.CommandOptionValue{
+			&discord.StringOption{
+				OptionName:  "schedule",
+				Description: "the channel name schedule",
+				Required:    true,
+				Choices:     choices,
+			},
+		}
+	}
+
+	cmd = command.LundeCommand{
+		HandleInteraction: wnr.handleInteraction,
+		CommandData: api.CreateCommandData{
+			Name:                     "channelnames",
+			Description:              "manage the channel name schedules",
+			DefaultMemberPermissions: discord.NewPermissions(discord.PermissionManageChannels),
+			Options: []discord.CommandOption{
+				&discord.SubcommandOption{
+					OptionName:  "preview",
+					Description: "show how channels and the guild would be changed by a schedule",
+					Options:     scheduleOption(),
+				},
+				&discord.SubcommandOption{
+					OptionName:  "apply",
+					Description: "apply a schedule now",
+					Options:     scheduleOption(),
+				},
+				&discord.SubcommandOption{
+					OptionName:  "revert",
+					Description: "restore what was changed by a schedule",
+					Options:     scheduleOption(),
+				},
+			},
+		},
+	}
+
+	return
+}
+
+func (wnr *channelNamesRunner) handleInteraction(
+	_ *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	subcommand, subOptions, err := command.Subcommand(options)
+	if err != nil {
+		return
+	}
+
+	sch, err := wnr.findSchedule(subOptions["schedule"].String())
+	if err != nil {
+		return
+	}
+
+	var msg string
+	switch subcommand {
+	case "preview":
+		msg, err = wnr.previewMessage(sch)
+	case "apply":
+		// theming can take longer than discord waits for a response
+		go func() {
+			applyErr := wnr.apply(sch)
+			if applyErr != nil {
+				logrus.Errorf("error occurred applying schedule %q: %v", sch.Name, applyErr)
+			}
+		}()
+		msg = fmt.Sprintf("applying schedule %q, discord allows renaming each channel twice "+
+			"per 10 minutes so renaming channels might take a while", sch.Name)
+	case "revert":
+		go func() {
+			revertErr := wnr.revert(sch)
+			if revertErr != nil {
+				logrus.Errorf("error occurred reverting schedule %q: %v", sch.Name, revertErr)
+			}
+		}()
+		msg = fmt.Sprintf("reverting schedule %q", sch.Name)
+	default:
+		err = fmt.Errorf("unknown subcommand %q", subcommand)
+	}
+	if err != nil {
+		return
+	}
+
+	response = &api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+	}
+	return
+}
+
+func (wnr *channelNamesRunner) previewMessage(sch schedule) (msg string, err error) {
+	chans, names, err := wnr.preview(sch)
+	if err != nil {
+		return
+	}
+
+	themeLines, err := wnr.previewTheme(sch)
+	if err != nil {
+		return
+	}
+
+	if len(chans) == 0 && len(themeLines) == 0 {
+		msg = fmt.Sprintf("channel name schedule %q would not change anything", sch.Name)
+		return
+	}
+
+	lines := []string{fmt.Sprintf("Channel name schedule %q would change:", sch.Name)}
+	for i, ch := range chans {
+		lines = append(lines, fmt.Sprintf("`%s` → `%s`", ch.Name, names[i]))
+	}
+	lines = append(lines, themeLines...)
+
+	msg = command.Truncate(strings.Join(lines, "\n"), 1999)
+	return
+}
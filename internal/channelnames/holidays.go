@@ -0,0 +1,83 @@
+package channelnames
+
+import (
+	"fmt"
+	"math/bits"
+	"time"
+
+	"github.com/polarbirds/lunde/internal/calendar"
+	"gopkg.in/robfig/cron.v2"
+)
+
+const (
+	// holidaysEve additionally fires on working days before public holidays
+	holidaysEve = "eve"
+	// holidaysAfter additionally fires on the first working day after public holidays, and skips
+	// firing on days off
+	holidaysAfter = "after"
+)
+
+// holidaySchedule fires like the wrapped spec, and also at the first hour and minute of the spec
+// around Norwegian public holidays, as decided by mode
+type holidaySchedule struct {
+	spec *cron.SpecSchedule
+	mode string
+}
+
+func newHolidaySchedule(sched cron.Schedule, mode string) (cron.Schedule, error) {
+	if mode != holidaysEve && mode != holidaysAfter {
+		return nil, fmt.Errorf("holidays must be %q or %q, was %q",
+			holidaysEve, holidaysAfter, mode)
+	}
+
+	spec, isSpec := sched.(*cron.SpecSchedule)
+	if !isSpec {
+		return nil, fmt.Errorf("holidays can not be combined with descriptors like @every")
+	}
+
+	return holidaySchedule{spec, mode}, nil
+}
+
+// Next implements cron.Schedule
+func (hs holidaySchedule) Next(t time.Time) time.Time {
+	next := hs.spec.Next(t)
+	for hs.mode == holidaysAfter && !next.IsZero() &&
+		calendar.IsDayOff(next.In(hs.spec.Location)) {
+		next = hs.spec.Next(next)
+	}
+
+	local := t.In(hs.spec.Location)
+	day := time.Date(local.Year(), local.Month(), local.Day(),
+		bits.TrailingZeros64(hs.spec.Hour), bits.TrailingZeros64(hs.spec.Minute), 0, 0,
+		hs.spec.Location)
+
+	for i := 0; i <= 366; i++ {
+		candidate := day.AddDate(0, 0, i)
+		if !next.IsZero() && !candidate.Before(next) {
+			break
+		}
+
+		if candidate.After(t) && hs.firesOn(candidate) {
+			return candidate
+		}
+	}
+
+	return next
+}
+
+func (hs holidaySchedule) firesOn(day time.Time) bool {
+	if calendar.IsDayOff(day) {
+		return false
+	}
+
+	switch hs.mode {
+	case holidaysEve:
+		_, isHoliday := calendar.NorwegianHoliday(day.AddDate(0, 0, 1))
+		return isHoliday
+	case holidaysAfter:
+		_, isHoliday := calendar.NorwegianHoliday(day.AddDate(0, 0, -1))
+		return isHoliday
+	}
+
+	return false
+}
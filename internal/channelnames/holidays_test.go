@@ -0,0 +1,77 @@
+package channelnames
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/robfig/cron.v2"
+)
+
+func TestHolidayScheduleNext(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(year int, month time.Month, day int, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, oslo)
+	}
+
+	tests := []struct {
+		name string
+		mode string
+		from time.Time
+		want time.Time
+	}{
+		{name: "eve of christmas", mode: holidaysEve,
+			from: at(2024, time.December, 23, 9), want: at(2024, time.December, 24, 8)},
+		{name: "spec before eve", mode: holidaysEve,
+			from: at(2024, time.December, 27, 10), want: at(2024, time.December, 30, 8)},
+		{name: "eve of new year", mode: holidaysEve,
+			from: at(2024, time.December, 30, 8), want: at(2024, time.December, 31, 8)},
+		{name: "across the new year", mode: holidaysEve,
+			from: at(2024, time.December, 31, 8), want: at(2025, time.January, 6, 8)},
+		{name: "eve of skjærtorsdag", mode: holidaysEve,
+			from: at(2025, time.April, 15, 12), want: at(2025, time.April, 16, 8)},
+		{name: "after easter instead of andre påskedag", mode: holidaysAfter,
+			from: at(2025, time.April, 18, 12), want: at(2025, time.April, 22, 8)},
+		{name: "after easter to the next spec", mode: holidaysAfter,
+			from: at(2025, time.April, 22, 8), want: at(2025, time.April, 28, 8)},
+		{name: "after new year across the year", mode: holidaysAfter,
+			from: at(2025, time.December, 30, 9), want: at(2026, time.January, 2, 8)},
+	}
+
+	// mondays at 08:00, so days fired only because of holidays stand out
+	spec, err := cron.Parse("TZ=Europe/Oslo 0 0 8 * * 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		sched, err := newHolidaySchedule(spec, tt.mode)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if got := sched.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewHolidayScheduleRejects(t *testing.T) {
+	spec, err := cron.Parse("0 0 8 * * 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = newHolidaySchedule(spec, "during"); err == nil {
+		t.Error("got no error for an unknown mode")
+	}
+
+	every, err := cron.Parse("@every 1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = newHolidaySchedule(every, holidaysEve); err == nil {
+		t.Error("got no error for a descriptor")
+	}
+}
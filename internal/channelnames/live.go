@@ -0,0 +1,161 @@
+package channelnames
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/polarbirds/lunde/internal/calendar"
+	"github.com/polarbirds/lunde/internal/scheduler"
+	"github.com/polarbirds/lunde/internal/tmplfuncs"
+	"gopkg.in/robfig/cron.v2"
+)
+
+const (
+	defaultLiveInterval = 10 * time.Minute
+	// minLiveInterval is the shortest interval that does not exceed discord's channel rename limit
+	minLiveInterval = renameWindow / renamesPerWindow
+)
+
+// liveChannel is a channel whose name is re-rendered from Template every Interval. Template is a Go
+// template executed with liveData
+type liveChannel struct {
+	Name      string            `yaml:"name"`
+	ChannelID discord.ChannelID `yaml:"channelID"`
+	Template  string            `yaml:"template"`
+	Interval  time.Duration     `yaml:"interval"`
+
+	tmpl *template.Template
+}
+
+// liveData is what the templates of live channels have access to
+type liveData struct {
+	MemberCount uint64
+	OnlineCount uint64
+	Now         time.Time
+	// DaysToFriday and DaysToWeekend are 0 on fridays and saturdays respectively
+	DaysToFriday  int
+	DaysToWeekend int
+	NextHoliday   string
+	DaysToHoliday int
+}
+
+type liveRenderer struct {
+	wnr *channelNamesRunner
+	// rendered holds the last name rendered for each channel, so unchanged names are not renamed
+	// again
+	rendered map[discord.ChannelID]string
+	mutex    sync.Mutex
+}
+
+// registerLiveChannels registers a job re-rendering the name of each live channel
+func (wnr *channelNamesRunner) registerLiveChannels() error {
+	lr := &liveRenderer{wnr: wnr, rendered: map[discord.ChannelID]string{}}
+
+	for i := range wnr.live {
+		lc := wnr.live[i]
+		if !lc.ChannelID.IsValid() || lc.Template == "" {
+			return errors.New("live channels need a channelID and a template")
+		}
+		if lc.Name == "" {
+			lc.Name = lc.ChannelID.String()
+		}
+
+		if lc.Interval == 0 {
+			lc.Interval = defaultLiveInterval
+		}
+		if lc.Interval < minLiveInterval {
+			return fmt.Errorf("live channel %q: interval %s is shorter than the minimum %s",
+				lc.Name, lc.Interval, minLiveInterval)
+		}
+
+		var err error
+		funcs := tmplfuncs.WithDaysUntil(wnr.srv.Location, time.Now)
+		lc.tmpl, err = template.New(lc.Name).Funcs(funcs).Parse(lc.Template)
+		if err != nil {
+			return fmt.Errorf("parsing template of live channel %q: %v", lc.Name, err)
+		}
+
+		err = wnr.srv.Scheduler.Add(scheduler.Job{
+			Name:        "livechannel-" + lc.Name,
+			Description: fmt.Sprintf("render the name of channel %s", lc.ChannelID.Mention()),
+			Schedule:    cron.Every(lc.Interval),
+			Run:         func() error { return lr.render(lc) },
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (lr *liveRenderer) render(lc liveChannel) error {
+	data, err := lr.data()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = lc.tmpl.Execute(&buf, data)
+	if err != nil {
+		return fmt.Errorf("executing template: %v", err)
+	}
+
+	name := strings.TrimSpace(buf.String())
+	if name == "" {
+		return errors.New("template rendered an empty name")
+	}
+
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+
+	if lr.rendered[lc.ChannelID] == name {
+		return nil
+	}
+
+	ch, err := lr.wnr.srv.Session.Channel(lc.ChannelID)
+	if err != nil {
+		return fmt.Errorf("getting channel: %v", err)
+	}
+
+	lr.rendered[lc.ChannelID] = name
+	if lr.wnr.queue.name(ch.ID, ch.Name) != name {
+		lr.wnr.queue.enqueue(ch.ID, name)
+	}
+
+	return nil
+}
+
+func (lr *liveRenderer) data() (data liveData, err error) {
+	guild, err := lr.wnr.srv.Session.GuildWithCount(lr.wnr.srv.GuildID)
+	if err != nil {
+		err = fmt.Errorf("getting member count: %v", err)
+		return
+	}
+
+	now := time.Now().In(lr.wnr.srv.Location)
+	data = liveData{
+		MemberCount:   guild.ApproximateMembers,
+		OnlineCount:   guild.ApproximatePresences,
+		Now:           now,
+		DaysToFriday:  (int(time.Friday) - int(now.Weekday()) + 7) % 7,
+		DaysToWeekend: (int(time.Saturday) - int(now.Weekday()) + 7) % 7,
+	}
+
+	for i := 0; i <= 366; i++ {
+		day := now.AddDate(0, 0, i)
+		if holiday, isHoliday := calendar.NorwegianHoliday(day); isHoliday {
+			data.NextHoliday = holiday.Name
+			data.DaysToHoliday = i
+			break
+		}
+	}
+
+	return
+}
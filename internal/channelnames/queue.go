@@ -0,0 +1,190 @@
+package channelnames
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/session"
+	"github.com/diamondburned/arikawa/v3/utils/httputil"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// discord allows renamesPerWindow renames of a channel per renameWindow
+	renamesPerWindow = 2
+	renameWindow     = 10 * time.Minute
+)
+
+// renameQueue renames channels in the background, spacing the renames of each channel so discord's
+// rate limit on channel names is not hit. Queueing a rename of a channel already queued replaces
+// the queued name
+type renameQueue struct {
+	// client does not retry rate limited requests, so a rate limited channel is put back in the
+	// queue instead of holding up the renames of the other channels
+	client *api.Client
+
+	// pending holds the queued name of each channel, renamed in the order of order
+	pending map[discord.ChannelID]string
+	order   []discord.ChannelID
+	// renamed holds the times of the renames of each channel within the last renameWindow
+	renamed map[discord.ChannelID][]time.Time
+	// blocked holds the time until which discord asked us to not rename a channel
+	blocked map[discord.ChannelID]time.Time
+	mutex   sync.Mutex
+
+	wake chan struct{}
+}
+
+func newRenameQueue(s *session.Session) *renameQueue {
+	client := *s.Client
+	client.Client = s.Client.Client.Copy()
+	client.Client.Retries = 1
+
+	q := &renameQueue{
+		client:  &client,
+		pending: map[discord.ChannelID]string{},
+		renamed: map[discord.ChannelID][]time.Time{},
+		blocked: map[discord.ChannelID]time.Time{},
+		wake:    make(chan struct{}, 1),
+	}
+
+	go q.work()
+	return q
+}
+
+// enqueue queues renaming the channel to the given name
+func (q *renameQueue) enqueue(channelID discord.ChannelID, name string) {
+	q.mutex.Lock()
+	if _, exists := q.pending[channelID]; !exists {
+		q.order = append(q.order, channelID)
+	}
+	q.pending[channelID] = name
+	q.mutex.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// name returns the name the channel will get when its queued rename is done, or the given current
+// name if no rename is queued
+func (q *renameQueue) name(channelID discord.ChannelID, current string) string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if name, exists := q.pending[channelID]; exists {
+		return name
+	}
+	return current
+}
+
+func (q *renameQueue) work() {
+	for {
+		channelID, name, wait := q.next()
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-q.wake:
+			}
+			continue
+		}
+
+		q.rename(channelID, name)
+	}
+}
+
+// next pops the first channel that can be renamed now. If none can, it returns how long to wait
+// until one can
+func (q *renameQueue) next() (channelID discord.ChannelID, name string, wait time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	wait = time.Hour
+	for i, id := range q.order {
+		readyAt := q.readyAt(id, now)
+		if readyAt.After(now) {
+			if readyAt.Sub(now) < wait {
+				wait = readyAt.Sub(now)
+			}
+			continue
+		}
+
+		name = q.pending[id]
+		delete(q.pending, id)
+		q.order = append(q.order[:i], q.order[i+1:]...)
+		return id, name, 0
+	}
+
+	return
+}
+
+// readyAt returns when the channel can be renamed next
+func (q *renameQueue) readyAt(channelID discord.ChannelID, now time.Time) time.Time {
+	recent := []time.Time{}
+	for _, t := range q.renamed[channelID] {
+		if now.Sub(t) < renameWindow {
+			recent = append(recent, t)
+		}
+	}
+	q.renamed[channelID] = recent
+
+	readyAt := q.blocked[channelID]
+	if len(recent) >= renamesPerWindow {
+		windowEnd := recent[len(recent)-renamesPerWindow].Add(renameWindow)
+		if windowEnd.After(readyAt) {
+			readyAt = windowEnd
+		}
+	}
+
+	return readyAt
+}
+
+func (q *renameQueue) rename(channelID discord.ChannelID, name string) {
+	err := q.client.ModifyChannel(channelID, api.ModifyChannelData{Name: name})
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var httpErr *httputil.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Status == httputil.StatusTooManyRequests {
+		retryAfter := parseRetryAfter(httpErr.Body)
+		logrus.Warnf("rate limited renaming channel %s to %q, retrying in %s",
+			channelID, name, retryAfter)
+		q.blocked[channelID] = time.Now().Add(retryAfter)
+
+		// retry unless a newer name was queued in the meantime
+		if _, exists := q.pending[channelID]; !exists {
+			q.pending[channelID] = name
+			q.order = append(q.order, channelID)
+		}
+		return
+	}
+
+	q.renamed[channelID] = append(q.renamed[channelID], time.Now())
+	if err != nil {
+		logrus.Errorf("error occurred renaming channel %s to %q: %v", channelID, name, err)
+		return
+	}
+	logrus.Infof("renamed channel %s to %q", channelID, name)
+}
+
+// parseRetryAfter reads how long discord asked us to wait from the body of a 429 response,
+// defaulting to a whole rename window
+func parseRetryAfter(body []byte) time.Duration {
+	var rateLimited struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+
+	err := json.Unmarshal(body, &rateLimited)
+	if err != nil || rateLimited.RetryAfter <= 0 {
+		return renameWindow
+	}
+
+	return time.Duration(rateLimited.RetryAfter*float64(time.Second)) + time.Second
+}
@@ -0,0 +1,107 @@
+package channelnames
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func testQueue() *renameQueue {
+	return &renameQueue{
+		pending: map[discord.ChannelID]string{},
+		renamed: map[discord.ChannelID][]time.Time{},
+		blocked: map[discord.ChannelID]time.Time{},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+func TestRenameQueueCollapses(t *testing.T) {
+	q := testQueue()
+	q.enqueue(1, "first")
+	q.enqueue(2, "other")
+	q.enqueue(1, "second")
+
+	if name := q.name(1, "current"); name != "second" {
+		t.Errorf("got queued name %q, want the latest name %q", name, "second")
+	}
+	if name := q.name(3, "current"); name != "current" {
+		t.Errorf("got name %q of a channel not queued, want %q", name, "current")
+	}
+
+	want := []struct {
+		channelID discord.ChannelID
+		name      string
+	}{{1, "second"}, {2, "other"}}
+	for _, w := range want {
+		channelID, name, wait := q.next()
+		if wait > 0 || channelID != w.channelID || name != w.name {
+			t.Fatalf("got next %s %q waiting %s, want %s %q", channelID, name, wait,
+				w.channelID, w.name)
+		}
+	}
+
+	if channelID, _, wait := q.next(); channelID.IsValid() || wait <= 0 {
+		t.Errorf("got next %s waiting %s from an empty queue, want to wait", channelID, wait)
+	}
+}
+
+func TestRenameQueueWaits(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		renamed  []time.Time
+		blocked  time.Time
+		wantWait time.Duration
+	}{
+		{
+			name:    "no renames",
+			renamed: nil,
+		},
+		{
+			name:    "one rename in the window",
+			renamed: []time.Time{now.Add(-time.Minute)},
+		},
+		{
+			name:     "window used up",
+			renamed:  []time.Time{now.Add(-8 * time.Minute), now.Add(-time.Minute)},
+			wantWait: 2 * time.Minute,
+		},
+		{
+			name:    "renames outside the window",
+			renamed: []time.Time{now.Add(-20 * time.Minute), now.Add(-11 * time.Minute)},
+		},
+		{
+			name:     "rate limited",
+			renamed:  []time.Time{now.Add(-time.Minute)},
+			blocked:  now.Add(5 * time.Minute),
+			wantWait: 5 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := testQueue()
+			q.renamed[1] = tt.renamed
+			q.blocked[1] = tt.blocked
+			q.enqueue(1, "name")
+
+			channelID, name, wait := q.next()
+			if tt.wantWait == 0 {
+				if channelID != 1 || name != "name" {
+					t.Errorf("got next %s %q waiting %s, want the channel renamed now",
+						channelID, name, wait)
+				}
+				return
+			}
+
+			if channelID.IsValid() {
+				t.Fatalf("got next %s, want to wait", channelID)
+			}
+			if diff := tt.wantWait - wait; diff < 0 || diff > time.Second {
+				t.Errorf("got wait %s, want %s", wait, tt.wantWait)
+			}
+		})
+	}
+}
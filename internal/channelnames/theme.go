@@ -0,0 +1,399 @@
+package channelnames
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/sirupsen/logrus"
+)
+
+var imageClient = http.Client{Timeout: 30 * time.Second}
+
+// roleTheme gives the role RoleID a new name and colour while a schedule is applied. An empty Name
+// or zero Color leaves that part of the role as is
+type roleTheme struct {
+	RoleID discord.RoleID `yaml:"roleID"`
+	Name   string         `yaml:"name"`
+	Color  discord.Color  `yaml:"color"`
+}
+
+// themedRole holds what a role was before and after a schedule was applied
+type themedRole struct {
+	OriginalName  string        `json:"originalName"`
+	OriginalColor discord.Color `json:"originalColor"`
+	Name          string        `json:"name"`
+	Color         discord.Color `json:"color"`
+}
+
+// themedIcon holds the guild icon from before a schedule was applied as a data URI, empty if the
+// guild had no icon, and the hash of the icon set by the schedule
+type themedIcon struct {
+	Original string `json:"original"`
+	Hash     string `json:"hash"`
+}
+
+// hasTheme returns whether the schedule changes more than channel names
+func (sch *schedule) hasTheme() bool {
+	return len(sch.Roles) > 0 || sch.GuildIcon != "" || sch.Nickname != ""
+}
+
+// previewTheme describes the changes to roles, guild icon and nickname the schedule would make
+func (wnr *channelNamesRunner) previewTheme(sch schedule) (lines []string, err error) {
+	if len(sch.Roles) > 0 {
+		var roles map[discord.RoleID]discord.Role
+		roles, err = wnr.roles()
+		if err != nil {
+			return
+		}
+
+		for _, rt := range sch.Roles {
+			role, exists := roles[rt.RoleID]
+			if !exists {
+				lines = append(lines, fmt.Sprintf("role %s does not exist", rt.RoleID))
+				continue
+			}
+
+			name, color := rt.apply(role)
+			lines = append(lines, fmt.Sprintf("role `%s` (%s) → `%s` (%s)",
+				role.Name, colorString(role.Color), name, colorString(color)))
+		}
+	}
+
+	if sch.GuildIcon != "" {
+		lines = append(lines, fmt.Sprintf("guild icon → %s", sch.GuildIcon))
+	}
+
+	if sch.Nickname != "" {
+		lines = append(lines, fmt.Sprintf("bot nickname → `%s`", sch.Nickname))
+	}
+
+	return
+}
+
+// applyTheme changes the roles, guild icon and nickname of the bot as given by the schedule,
+// recording what they were in the snapshot. Everything is attempted even if some of it fails
+func (wnr *channelNamesRunner) applyTheme(sch schedule, snap *snapshot) error {
+	var errs []string
+
+	if len(sch.Roles) > 0 {
+		err := wnr.applyRoles(sch, snap)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if sch.GuildIcon != "" {
+		err := wnr.applyGuildIcon(sch, snap)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("setting guild icon: %v", err))
+		}
+	}
+
+	if sch.Nickname != "" {
+		err := wnr.applyNickname(sch, snap)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("setting nickname: %v", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// revertTheme restores the roles, guild icon and nickname recorded in the snapshot. Those changed
+// by someone else since the schedule was applied are left alone
+func (wnr *channelNamesRunner) revertTheme(snap *snapshot) error {
+	var errs []string
+
+	if len(snap.Roles) > 0 {
+		err := wnr.revertRoles(snap)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if snap.GuildIcon != nil {
+		err := wnr.revertGuildIcon(snap)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("restoring guild icon: %v", err))
+		}
+	}
+
+	if snap.Nickname != nil {
+		err := wnr.revertNickname(snap)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("restoring nickname: %v", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (wnr *channelNamesRunner) applyRoles(sch schedule, snap *snapshot) error {
+	roles, err := wnr.roles()
+	if err != nil {
+		return err
+	}
+
+	if snap.Roles == nil {
+		snap.Roles = map[discord.RoleID]themedRole{}
+	}
+
+	var errs []string
+	for _, rt := range sch.Roles {
+		role, exists := roles[rt.RoleID]
+		if !exists {
+			errs = append(errs, fmt.Sprintf("role %s does not exist", rt.RoleID))
+			continue
+		}
+
+		name, color := rt.apply(role)
+		_, err = wnr.srv.Session.ModifyRole(wnr.srv.GuildID, role.ID, api.ModifyRoleData{
+			Name:  option.NewNullableString(name),
+			Color: nullableColor(color),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("modifying role %q: %v", role.Name, err))
+			continue
+		}
+
+		// keep the role from before the schedule was first applied, if applied repeatedly
+		themed := themedRole{OriginalName: role.Name, OriginalColor: role.Color}
+		if previous, exists := snap.Roles[role.ID]; exists {
+			themed = previous
+		}
+		themed.Name = name
+		themed.Color = color
+		snap.Roles[role.ID] = themed
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (wnr *channelNamesRunner) revertRoles(snap *snapshot) error {
+	roles, err := wnr.roles()
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for roleID, themed := range snap.Roles {
+		role, exists := roles[roleID]
+		if !exists || role.Name != themed.Name || role.Color != themed.Color {
+			logrus.Infof("not reverting role %s to %q as it was changed or deleted since",
+				roleID, themed.OriginalName)
+			continue
+		}
+
+		_, err = wnr.srv.Session.ModifyRole(wnr.srv.GuildID, roleID, api.ModifyRoleData{
+			Name:  option.NewNullableString(themed.OriginalName),
+			Color: nullableColor(themed.OriginalColor),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("restoring role %q: %v", themed.OriginalName, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (wnr *channelNamesRunner) applyGuildIcon(sch schedule, snap *snapshot) error {
+	icon, err := loadImage(sch.GuildIcon)
+	if err != nil {
+		return err
+	}
+
+	guild, err := wnr.srv.Session.Guild(wnr.srv.GuildID)
+	if err != nil {
+		return fmt.Errorf("getting guild: %v", err)
+	}
+
+	original := ""
+	if snap.GuildIcon != nil {
+		original = snap.GuildIcon.Original
+	} else if guild.Icon != "" {
+		var img *api.Image
+		img, err = loadImage(guild.IconURLWithType(discord.PNGImage))
+		if err != nil {
+			return fmt.Errorf("saving current icon: %v", err)
+		}
+
+		var encoded []byte
+		encoded, err = img.Encode()
+		if err != nil {
+			return fmt.Errorf("encoding current icon: %v", err)
+		}
+		original = string(encoded)
+	}
+
+	guild, err = wnr.srv.Session.ModifyGuild(wnr.srv.GuildID, api.ModifyGuildData{Icon: icon})
+	if err != nil {
+		return err
+	}
+
+	snap.GuildIcon = &themedIcon{Original: original, Hash: guild.Icon}
+	return nil
+}
+
+func (wnr *channelNamesRunner) revertGuildIcon(snap *snapshot) error {
+	guild, err := wnr.srv.Session.Guild(wnr.srv.GuildID)
+	if err != nil {
+		return fmt.Errorf("getting guild: %v", err)
+	}
+
+	if guild.Icon != snap.GuildIcon.Hash {
+		logrus.Info("not reverting guild icon as it was changed since")
+		return nil
+	}
+
+	icon := api.NullImage
+	if snap.GuildIcon.Original != "" {
+		icon, err = api.DecodeImage([]byte(snap.GuildIcon.Original))
+		if err != nil {
+			return fmt.Errorf("decoding original icon: %v", err)
+		}
+	}
+
+	_, err = wnr.srv.Session.ModifyGuild(wnr.srv.GuildID, api.ModifyGuildData{Icon: icon})
+	return err
+}
+
+func (wnr *channelNamesRunner) applyNickname(sch schedule, snap *snapshot) error {
+	nick, err := wnr.nickname()
+	if err != nil {
+		return err
+	}
+
+	err = wnr.srv.Session.ModifyCurrentMember(wnr.srv.GuildID, sch.Nickname)
+	if err != nil {
+		return err
+	}
+
+	if snap.Nickname != nil {
+		nick = snap.Nickname.Original
+	}
+	snap.Nickname = &renamed{Original: nick, Renamed: sch.Nickname}
+	return nil
+}
+
+func (wnr *channelNamesRunner) revertNickname(snap *snapshot) error {
+	nick, err := wnr.nickname()
+	if err != nil {
+		return err
+	}
+
+	if nick != snap.Nickname.Renamed {
+		logrus.Infof("not reverting nickname to %q as it was changed since",
+			snap.Nickname.Original)
+		return nil
+	}
+
+	return wnr.srv.Session.ModifyCurrentMember(wnr.srv.GuildID, snap.Nickname.Original)
+}
+
+func (wnr *channelNamesRunner) nickname() (string, error) {
+	me, err := wnr.srv.Session.Me()
+	if err != nil {
+		return "", fmt.Errorf("getting bot user: %v", err)
+	}
+
+	member, err := wnr.srv.Session.Member(wnr.srv.GuildID, me.ID)
+	if err != nil {
+		return "", fmt.Errorf("getting bot member: %v", err)
+	}
+
+	return member.Nick, nil
+}
+
+func (wnr *channelNamesRunner) roles() (map[discord.RoleID]discord.Role, error) {
+	roles, err := wnr.srv.Session.Roles(wnr.srv.GuildID)
+	if err != nil {
+		return nil, fmt.Errorf("getting roles: %v", err)
+	}
+
+	byID := make(map[discord.RoleID]discord.Role, len(roles))
+	for _, role := range roles {
+		byID[role.ID] = role
+	}
+	return byID, nil
+}
+
+// apply returns the name and colour of the role after the theme is applied
+func (rt roleTheme) apply(role discord.Role) (name string, color discord.Color) {
+	name, color = role.Name, role.Color
+	if rt.Name != "" {
+		name = rt.Name
+	}
+	if rt.Color != 0 {
+		color = rt.Color
+	}
+	return
+}
+
+// nullableColor returns the color to send to discord, which resets roles to the default colour
+// when given null rather than 0
+func nullableColor(color discord.Color) discord.Color {
+	if color == 0 {
+		return discord.NullColor
+	}
+	return color
+}
+
+func colorString(color discord.Color) string {
+	if color == 0 {
+		return "no colour"
+	}
+	return fmt.Sprintf("#%06x", int32(color))
+}
+
+// loadImage reads the image at the given URL or file path
+func loadImage(src string) (*api.Image, error) {
+	var content []byte
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		resp, err := imageClient.Get(src)
+		if err != nil {
+			return nil, fmt.Errorf("getting %s: %v", src, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("getting %s: status %s", src, resp.Status)
+		}
+
+		content, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", src, err)
+		}
+	} else {
+		var err error
+		content, err = ioutil.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", src, err)
+		}
+	}
+
+	img := &api.Image{ContentType: http.DetectContentType(content), Content: content}
+	err := img.Validate(0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", src, err)
+	}
+	return img, nil
+}
@@ -0,0 +1,414 @@
+package autoreact
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/haraldfw/cfger"
+	"github.com/jmcvetta/randutil"
+	"github.com/polarbirds/lunde/internal/command"
+	"github.com/polarbirds/lunde/internal/server"
+	"github.com/polarbirds/lunde/internal/trigger"
+	"github.com/sirupsen/logrus"
+)
+
+const storeName = "autoreactions"
+
+type autoReactConfig struct {
+	AutoReactions []*rule `yaml:"autoReactions"`
+}
+
+// rule reacts with one of Emojis, chosen by weight, to messages firing the trigger
+type rule struct {
+	Name            string          `yaml:"name" json:"name"`
+	Emojis          []weightedEmoji `yaml:"emojis" json:"emojis"`
+	trigger.Trigger `yaml:",inline"`
+
+	fromConfig bool
+}
+
+type weightedEmoji struct {
+	Emoji  discord.APIEmoji `yaml:"emoji" json:"emoji"`
+	Weight int              `yaml:"weight" json:"weight,omitempty"`
+}
+
+type autoReactHandler struct {
+	srv         *server.Server
+	rules       []*rule
+	storedRules []*rule
+	mutex       sync.RWMutex
+}
+
+// CreateCommand creates a lunde command to manage automatic reactions to messages. Rules are read
+// from the autoReactions section of the config, and rules added with the command are persisted
+func CreateCommand(srv *server.Server) (cmd command.LundeCommand, err error) {
+	ah := autoReactHandler{srv: srv}
+
+	var cfg autoReactConfig
+	_, err = cfger.ReadStructuredCfgRecursive("env::CONFIG", &cfg)
+	if err != nil {
+		err = fmt.Errorf("reading autoreact config: %v", err)
+		return
+	}
+
+	err = srv.Store.Load(storeName, &ah.storedRules)
+	if err != nil {
+		err = fmt.Errorf("loading autoreactions: %v", err)
+		return
+	}
+
+	for _, r := range cfg.AutoReactions {
+		r.fromConfig = true
+	}
+	for _, r := range append(cfg.AutoReactions, ah.storedRules...) {
+		err = r.compile()
+		if err != nil {
+			err = fmt.Errorf("autoreaction %q: %v", r.Name, err)
+			return
+		}
+		ah.rules = append(ah.rules, r)
+	}
+
+	srv.AddMessageCreateHandler(ah.handleMessageCreate)
+
+	cmd = command.LundeCommand{
+		HandleInteraction: ah.handleInteraction,
+		CommandData: api.CreateCommandData{
+			Name:                     "autoreact",
+			Description:              "manage automatic reactions to messages",
+			DefaultMemberPermissions: discord.NewPermissions(discord.PermissionManageMessages),
+			Options: []discord.CommandOption{
+				&discord.SubcommandOption{
+					OptionName:  "add",
+					Description: "react to messages matching a pattern or words",
+					Options: []discord.CommandOptionValue{
+						&discord.StringOption{
+							OptionName:  "name",
+							Description: "name of the rule",
+							Required:    true,
+						},
+						&discord.StringOption{
+							OptionName: "emojis",
+							Description: "space separated emojis to choose from, optionally " +
+								"weighted like 🍕=3",
+							Required: true,
+						},
+						&discord.StringOption{
+							OptionName:  "pattern",
+							Description: "regular expression to match messages against",
+						},
+						&discord.StringOption{
+							OptionName:  "words",
+							Description: "comma separated words to match messages against",
+						},
+						&discord.ChannelOption{
+							OptionName:   "channel",
+							Description:  "only react in this channel",
+							ChannelTypes: []discord.ChannelType{discord.GuildText},
+						},
+						&discord.NumberOption{
+							OptionName:  "probability",
+							Description: "chance of reacting to a matching message, 0 to 1",
+							Min:         option.NewFloat(0),
+							Max:         option.NewFloat(1),
+						},
+						&discord.StringOption{
+							OptionName:  "cooldown",
+							Description: "minimum time between reactions in a channel, like 10m",
+						},
+					},
+				},
+				&discord.SubcommandOption{
+					OptionName:  "list",
+					Description: "list all automatic reactions",
+				},
+				&discord.SubcommandOption{
+					OptionName:  "remove",
+					Description: "remove an automatic reaction",
+					Options: []discord.CommandOptionValue{
+						&discord.StringOption{
+							OptionName:  "name",
+							Description: "name of the rule to remove",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return
+}
+
+func (r *rule) compile() error {
+	if r.Name == "" {
+		return errors.New("rule has no name")
+	}
+
+	if len(r.Emojis) == 0 {
+		return errors.New("rule has no emojis")
+	}
+
+	for i, e := range r.Emojis {
+		emoji, err := command.ParseEmoji(string(e.Emoji))
+		if err != nil {
+			return fmt.Errorf("parsing emoji: %v", err)
+		}
+		r.Emojis[i].Emoji = emoji
+	}
+
+	return r.Compile()
+}
+
+func (r *rule) chooseEmoji() (discord.APIEmoji, error) {
+	choices := make([]randutil.Choice, 0, len(r.Emojis))
+	for _, e := range r.Emojis {
+		weight := e.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		choices = append(choices, randutil.Choice{
+			Weight: weight,
+			Item:   e.Emoji,
+		})
+	}
+
+	chosen, err := randutil.WeightedChoice(choices)
+	if err != nil {
+		return "", err
+	}
+
+	return chosen.Item.(discord.APIEmoji), nil
+}
+
+func (ah *autoReactHandler) handleMessageCreate(ev *gateway.MessageCreateEvent) {
+	if ev.Author.Bot {
+		return
+	}
+
+	// the emojis are chosen under the lock and reacted with after it is released, so reacting
+	// over the network does not hold up changes to the rules
+	emojis := []discord.APIEmoji{}
+	ah.mutex.RLock()
+	for _, r := range ah.rules {
+		if _, fired := r.Fire(ev.ChannelID, ev.Content); !fired {
+			continue
+		}
+
+		emoji, err := r.chooseEmoji()
+		if err != nil {
+			logrus.Errorf("error occurred choosing emoji for autoreaction %q: %v", r.Name, err)
+			continue
+		}
+		emojis = append(emojis, emoji)
+	}
+	ah.mutex.RUnlock()
+
+	for _, emoji := range emojis {
+		err := ah.srv.Session.React(ev.ChannelID, ev.ID, emoji)
+		if err != nil {
+			logrus.Errorf("error occurred adding reaction: %v", err)
+		}
+	}
+}
+
+func (ah *autoReactHandler) handleInteraction(
+	_ *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	subcommand, subOptions, err := command.Subcommand(options)
+	if err != nil {
+		return
+	}
+
+	var msg string
+	switch subcommand {
+	case "add":
+		msg, err = ah.add(subOptions)
+	case "list":
+		msg = ah.list()
+	case "remove":
+		msg, err = ah.remove(subOptions["name"].String())
+	default:
+		err = fmt.Errorf("unknown subcommand %q", subcommand)
+	}
+	if err != nil {
+		return
+	}
+
+	response = &api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+	}
+	return
+}
+
+func (ah *autoReactHandler) add(options map[string]discord.CommandInteractionOption) (
+	msg string, err error,
+) {
+	r := &rule{Name: options["name"].String()}
+	r.Pattern = options["pattern"].String()
+
+	for _, word := range strings.Split(options["words"].String(), ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			r.Words = append(r.Words, word)
+		}
+	}
+
+	r.Emojis, err = parseWeightedEmojis(options["emojis"].String())
+	if err != nil {
+		return
+	}
+
+	channelFlake, err := options["channel"].SnowflakeValue()
+	if err != nil {
+		err = fmt.Errorf("parsing channel flake: %v", err)
+		return
+	}
+	if channelFlake != 0 {
+		r.Channels = []discord.ChannelID{discord.ChannelID(channelFlake)}
+	}
+
+	r.Probability, err = options["probability"].FloatValue()
+	if err != nil {
+		err = fmt.Errorf("parsing probability: %v", err)
+		return
+	}
+
+	if cooldown := options["cooldown"].String(); cooldown != "" {
+		r.Cooldown, err = time.ParseDuration(cooldown)
+		if err != nil {
+			err = fmt.Errorf("parsing cooldown: %v", err)
+			return
+		}
+	}
+
+	err = r.compile()
+	if err != nil {
+		return
+	}
+
+	ah.mutex.Lock()
+	defer ah.mutex.Unlock()
+
+	for _, existing := range ah.rules {
+		if existing.Name == r.Name {
+			err = fmt.Errorf("an autoreaction named %q already exists", r.Name)
+			return
+		}
+	}
+
+	err = ah.srv.Store.Save(storeName, append(ah.storedRules, r))
+	if err != nil {
+		err = fmt.Errorf("saving autoreactions: %v", err)
+		return
+	}
+	ah.storedRules = append(ah.storedRules, r)
+	ah.rules = append(ah.rules, r)
+
+	msg = fmt.Sprintf("added autoreaction %q: %s", r.Name, r.Describe())
+	return
+}
+
+func (ah *autoReactHandler) list() string {
+	ah.mutex.RLock()
+	defer ah.mutex.RUnlock()
+
+	if len(ah.rules) == 0 {
+		return "no autoreactions are set up"
+	}
+
+	lines := []string{"Autoreactions:"}
+	for _, r := range ah.rules {
+		emojis := make([]string, len(r.Emojis))
+		for i, e := range r.Emojis {
+			emojis[i] = command.EmojiString(e.Emoji)
+			if e.Weight > 1 {
+				emojis[i] += "=" + strconv.Itoa(e.Weight)
+			}
+		}
+
+		line := fmt.Sprintf("**%s**: %s on %s", r.Name, strings.Join(emojis, " "), r.Describe())
+		if r.fromConfig {
+			line += " (config)"
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (ah *autoReactHandler) remove(name string) (msg string, err error) {
+	ah.mutex.Lock()
+	defer ah.mutex.Unlock()
+
+	for i, r := range ah.storedRules {
+		if r.Name != name {
+			continue
+		}
+
+		storedRules := append(append([]*rule{}, ah.storedRules[:i]...), ah.storedRules[i+1:]...)
+		err = ah.srv.Store.Save(storeName, storedRules)
+		if err != nil {
+			err = fmt.Errorf("saving autoreactions: %v", err)
+			return
+		}
+		ah.storedRules = storedRules
+
+		for j, active := range ah.rules {
+			if active == r {
+				ah.rules = append(ah.rules[:j], ah.rules[j+1:]...)
+				break
+			}
+		}
+
+		msg = fmt.Sprintf("removed autoreaction %q", name)
+		return
+	}
+
+	for _, r := range ah.rules {
+		if r.Name == name {
+			err = fmt.Errorf("autoreaction %q is defined in the config and can not be removed", name)
+			return
+		}
+	}
+
+	err = fmt.Errorf("found no autoreaction named %q", name)
+	return
+}
+
+// parseWeightedEmojis parses space separated emojis, each optionally suffixed by =weight
+func parseWeightedEmojis(text string) (emojis []weightedEmoji, err error) {
+	for _, field := range strings.Fields(text) {
+		we := weightedEmoji{}
+		emojiText := field
+		if i := strings.LastIndex(field, "="); i != -1 {
+			emojiText = field[:i]
+			we.Weight, err = strconv.Atoi(field[i+1:])
+			if err != nil || we.Weight < 1 {
+				err = fmt.Errorf("weight of %q must be a positive whole number", field)
+				return
+			}
+		}
+
+		we.Emoji, err = command.ParseEmoji(emojiText)
+		if err != nil {
+			err = fmt.Errorf("parsing emoji: %v", err)
+			return
+		}
+		emojis = append(emojis, we)
+	}
+
+	if len(emojis) == 0 {
+		err = errors.New("no emojis given")
+	}
+	return
+}
@@ -1,11 +1,27 @@
 package command
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
 	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/session"
+)
+
+var (
+	customEmojiPattern    = regexp.MustCompile(`^<a?:(\w+):(\d+)>$`)
+	customAPIEmojiPattern = regexp.MustCompile(`^\w+:\d+$`)
 )
 
+// ComponentIDSeparator separates the name of the command a message component belongs to from the
+// rest of its custom ID
+const ComponentIDSeparator = ":"
+
 // LundeCommand is data about a command and the function to handle interactions in the way described
 // by the data
 type LundeCommand struct {
@@ -14,4 +30,137 @@ type LundeCommand struct {
 		event *gateway.InteractionCreateEvent,
 		options map[string]discord.CommandInteractionOption,
 	) (*api.InteractionResponseData, error)
+	// HandleComponent is optional, and handles interactions with message components like buttons
+	// whose custom IDs are made by ComponentID for this command. The message of the component is
+	// replaced by the returned data
+	HandleComponent func(
+		event *gateway.InteractionCreateEvent,
+		customID string,
+	) (*api.InteractionResponseData, error)
+	// HandleAutocomplete is optional, and suggests values for the options of the command marked
+	// for autocompletion while they are typed
+	HandleAutocomplete func(
+		event *gateway.InteractionCreateEvent,
+		options discord.AutocompleteOptions,
+	) (api.AutocompleteChoices, error)
+}
+
+// ComponentID creates the custom ID of a message component, so interactions with it are routed to
+// the HandleComponent of the named command with the given custom ID
+func ComponentID(commandName string, customID string) discord.ComponentID {
+	return discord.ComponentID(commandName + ComponentIDSeparator + customID)
+}
+
+// Subcommand returns the name and options of the subcommand that was invoked, given the options of
+// the top-level command
+func Subcommand(options map[string]discord.CommandInteractionOption) (
+	name string,
+	subOptions map[string]discord.CommandInteractionOption,
+	err error,
+) {
+	for _, op := range options {
+		if op.Type != discord.SubcommandOptionType {
+			continue
+		}
+
+		subOptions = make(map[string]discord.CommandInteractionOption)
+		for _, subOp := range op.Options {
+			subOptions[subOp.Name] = subOp
+		}
+		return op.Name, subOptions, nil
+	}
+
+	err = errors.New("no subcommand given")
+	return
+}
+
+// FocusedOption returns the option being typed in an autocomplete interaction, also if it is the
+// option of a subcommand
+func FocusedOption(options discord.AutocompleteOptions) discord.AutocompleteOption {
+	for _, op := range options {
+		if op.Focused {
+			return op
+		}
+		if op.Type == discord.SubcommandOptionType {
+			if focused := FocusedOption(op.Options); focused.Focused {
+				return focused
+			}
+		}
+	}
+	return discord.AutocompleteOption{}
+}
+
+// Truncate shortens the text to at most max bytes, without splitting a character
+func Truncate(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+
+	for max > 0 && !utf8.RuneStart(text[max]) {
+		max--
+	}
+	return text[:max]
+}
+
+// ParseEmoji parses an emoji as written in a message or option, either a unicode emoji or a custom
+// emoji on the form <:name:id> or name:id, into a string usable with the API
+func ParseEmoji(text string) (discord.APIEmoji, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("no emoji given")
+	}
+
+	if match := customEmojiPattern.FindStringSubmatch(text); match != nil {
+		return discord.APIEmoji(match[1] + ":" + match[2]), nil
+	}
+
+	if customAPIEmojiPattern.MatchString(text) {
+		return discord.APIEmoji(text), nil
+	}
+
+	if strings.ContainsAny(text, "<>: ") {
+		return "", fmt.Errorf("%q is not an emoji", text)
+	}
+
+	return discord.APIEmoji(text), nil
+}
+
+// EmojiString formats an emoji parsed by ParseEmoji the way it is written in messages
+func EmojiString(emoji discord.APIEmoji) string {
+	if strings.Contains(string(emoji), ":") {
+		return "<:" + string(emoji) + ">"
+	}
+	return string(emoji)
+}
+
+// RequirePermission returns an error if the member invoking the interaction does not have the
+// given permission in the channel of the interaction. It is for subcommands needing more
+// permissions than the rest of their command
+func RequirePermission(
+	s *session.Session, event *gateway.InteractionCreateEvent, perm discord.Permissions,
+) error {
+	if event.Member == nil {
+		return errors.New("command can only be used in a guild")
+	}
+
+	guild, err := s.Guild(event.GuildID)
+	if err != nil {
+		return fmt.Errorf("getting guild: %v", err)
+	}
+
+	ch, err := s.Channel(event.ChannelID)
+	if err != nil {
+		return fmt.Errorf("getting channel: %v", err)
+	}
+
+	roles, err := s.Roles(event.GuildID)
+	if err != nil {
+		return fmt.Errorf("getting roles: %v", err)
+	}
+
+	if !discord.CalcOverrides(*guild, *ch, *event.Member, roles).Has(perm) {
+		return errors.New("you do not have permission to do that")
+	}
+
+	return nil
 }
@@ -57,7 +57,7 @@ func (ch *countHandler) handleInteraction(
 ) (
 	response *api.InteractionResponseData, err error,
 ) {
-	if !ch.srv.BuildingDataDone {
+	if !ch.srv.IsDataBuilt() {
 		err = errors.New("building data not done, try again later")
 		return
 	}
@@ -1,106 +1,326 @@
 package define
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
 	"github.com/diamondburned/arikawa/v3/gateway"
 	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/haraldfw/cfger"
 	"github.com/polarbirds/lunde/internal/command"
 	"github.com/polarbirds/lunde/internal/server"
+	"github.com/sirupsen/logrus"
 )
 
-type udResponse struct {
-	List []struct {
-		Word       string `json:"word"`
-		Definition string `json:"definition"`
-		Example    string `json:"example"`
-		ThumbsUp   int    `json:"thumbs_up"`
-		ThumbsDown int    `json:"thumbs_down"`
-	} `json:"list"`
+const (
+	// sourceTimeout is how long each source may take to define a term
+	sourceTimeout = 1500 * time.Millisecond
+	// lookupTimeout is how long all the sources tried may take, as discord waits three seconds
+	// for the answer to a lookup
+	lookupTimeout = 2500 * time.Millisecond
+)
+
+// sourceNames are the names of the sources, in the order they are tried by default
+var sourceNames = []string{"urbandictionary", "freedictionary", "wiktionary"}
+
+type defineConfig struct {
+	Define struct {
+		// Sources are the names of the sources tried in order until one defines the term
+		Sources []string `yaml:"sources"`
+		// BaseURLs replace the URLs of the APIs of the sources, by source name
+		BaseURLs map[string]string `yaml:"baseURLs"`
+	} `yaml:"define"`
+}
+
+type defineHandler struct {
+	srv      *server.Server
+	definers map[string]Definer
+	// order is the order definers are tried in when no source is given
+	order    []Definer
+	glossary glossary
+	mutex    sync.Mutex
 }
 
-// CreateCommand creates the define command
-func CreateCommand(_ *server.Server) (cmd command.LundeCommand, err error) {
-	cmd = command.LundeCommand{
-		HandleInteraction: handleInteraction,
-		CommandData: api.CreateCommandData{
-			Name: "define",
-			Description: "fetch a definition of a word of phrase from a reputable and " +
-				"renowned source of knowledge",
-			Options: []discord.CommandOption{
-				&discord.StringOption{
-					OptionName:  "term",
-					Description: "term to fetch definition for",
-					Required:    true,
+// CreateCommands returns the creators of the define command, which looks terms up in the glossary
+// of the guild before the configured sources, and the glossary command, which adds and removes
+// glossary entries. The commands share a handler
+func CreateCommands() (createDefine server.CreateCommand, createGlossary server.CreateCommand) {
+	var dh *defineHandler
+	handler := func(srv *server.Server) (*defineHandler, error) {
+		if dh != nil {
+			return dh, nil
+		}
+
+		created, err := newDefineHandler(srv)
+		if err != nil {
+			return nil, err
+		}
+		dh = created
+		return dh, nil
+	}
+
+	createDefine = func(srv *server.Server) (cmd command.LundeCommand, err error) {
+		dh, err := handler(srv)
+		if err != nil {
+			return
+		}
+
+		sourceChoices := []discord.StringChoice{}
+		for _, name := range sourceNames {
+			sourceChoices = append(sourceChoices, discord.StringChoice{Name: name, Value: name})
+		}
+
+		cmd = command.LundeCommand{
+			HandleInteraction: dh.handleDefine,
+			HandleComponent:   dh.handleComponent,
+			CommandData: api.CreateCommandData{
+				Name: "define",
+				Description: "fetch a definition of a word of phrase from a reputable and " +
+					"renowned source of knowledge",
+				Options: []discord.CommandOption{
+					&discord.StringOption{
+						OptionName:  "term",
+						Description: "term to fetch definition for",
+						Required:    true,
+					},
+					&discord.StringOption{
+						OptionName: "source",
+						Description: "where to look for definitions, defaults to the " +
+							"glossary and then the sources in order",
+						Choices: sourceChoices,
+					},
+				},
+			},
+		}
+		return
+	}
+
+	createGlossary = func(srv *server.Server) (cmd command.LundeCommand, err error) {
+		dh, err := handler(srv)
+		if err != nil {
+			return
+		}
+
+		cmd = command.LundeCommand{
+			HandleInteraction: dh.handleGlossary,
+			CommandData: api.CreateCommandData{
+				Name:        "glossary",
+				Description: "manage the definitions of terms in the glossary of the server",
+				Options: []discord.CommandOption{
+					&discord.SubcommandOption{
+						OptionName:  "add",
+						Description: "add a definition of a term to the glossary of the server",
+						Options: []discord.CommandOptionValue{
+							&discord.StringOption{
+								OptionName:  "term",
+								Description: "term to define",
+								Required:    true,
+							},
+							&discord.StringOption{
+								OptionName:  "definition",
+								Description: "what the term means",
+								Required:    true,
+							},
+						},
+					},
+					&discord.SubcommandOption{
+						OptionName:  "remove",
+						Description: "remove a definition from the glossary of the server",
+						Options: []discord.CommandOptionValue{
+							&discord.IntegerOption{
+								OptionName:  "id",
+								Description: "ID of the entry, as shown when defining the term",
+								Required:    true,
+							},
+						},
+					},
 				},
 			},
-		},
+		}
+		return
 	}
+
 	return
 }
 
-func handleInteraction(
+func newDefineHandler(srv *server.Server) (dh *defineHandler, err error) {
+	var cfg defineConfig
+	_, err = cfger.ReadStructuredCfgRecursive("env::CONFIG", &cfg)
+	if err != nil {
+		err = fmt.Errorf("reading define config: %v", err)
+		return
+	}
+
+	baseURL := func(name string, defaultURL string) string {
+		if u := cfg.Define.BaseURLs[name]; u != "" {
+			return strings.TrimSuffix(u, "/")
+		}
+		return defaultURL
+	}
+
+	dh = &defineHandler{
+		srv:      srv,
+		definers: map[string]Definer{},
+		glossary: glossary{NextID: 1},
+	}
+	err = dh.loadGlossary()
+	if err != nil {
+		return
+	}
+
+	for _, d := range []Definer{
+		urbanDictionary{baseURL: baseURL("urbandictionary", "https://api.urbandictionary.com")},
+		freeDictionary{baseURL: baseURL("freedictionary", "https://api.dictionaryapi.dev")},
+		wiktionary{baseURL: baseURL("wiktionary", "https://en.wiktionary.org")},
+	} {
+		dh.definers[d.Name()] = d
+	}
+
+	if len(cfg.Define.Sources) == 0 {
+		cfg.Define.Sources = sourceNames
+	}
+	for _, name := range cfg.Define.Sources {
+		d, exists := dh.definers[name]
+		if !exists {
+			err = fmt.Errorf("unknown define source %q", name)
+			return
+		}
+		dh.order = append(dh.order, d)
+	}
+	return
+}
+
+func (dh *defineHandler) handleDefine(
 	_ *gateway.InteractionCreateEvent,
 	options map[string]discord.CommandInteractionOption,
 ) (
 	response *api.InteractionResponseData, err error,
 ) {
-	term := options["term"]
-	var res *http.Response
-	res, err = http.Get("http://api.urbandictionary.com/v0/define?term=" +
-		url.QueryEscape(term.String()))
+	return dh.lookup(options)
+}
+
+func (dh *defineHandler) handleGlossary(
+	event *gateway.InteractionCreateEvent,
+	options map[string]discord.CommandInteractionOption,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	subcommand, subOptions, err := command.Subcommand(options)
 	if err != nil {
-		err = fmt.Errorf("error contacting define server: %v", err)
 		return
 	}
 
-	defer res.Body.Close()
-
-	bodBytes, err := ioutil.ReadAll(res.Body)
+	var msg string
+	switch subcommand {
+	case "add":
+		msg, err = dh.addEntry(event, subOptions)
+	case "remove":
+		msg, err = dh.removeEntry(event, subOptions)
+	default:
+		err = fmt.Errorf("unknown subcommand %q", subcommand)
+	}
 	if err != nil {
-		err = fmt.Errorf(
-			"failed reading response body from define-server (status was %s): %v",
-			res.Status, err,
-		)
 		return
 	}
 
-	var udRes udResponse
-	err = json.Unmarshal(bodBytes, &udRes)
-	if err != nil {
-		err = errors.New("no definitions found")
+	response = &api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+	}
+	return
+}
+
+// lookup responds with the glossary entries of the term, or the definitions of the first source
+// defining it if it is not in the glossary or a source is given
+func (dh *defineHandler) lookup(options map[string]discord.CommandInteractionOption) (
+	response *api.InteractionResponseData, err error,
+) {
+	term := strings.TrimSpace(options["term"].String())
+	if term == "" {
+		err = errors.New("no term given")
 		return
 	}
 
-	if len(udRes.List) == 0 {
-		err = fmt.Errorf("no definition returned for the term: %s", term)
+	definers := dh.order
+	if source := options["source"].String(); source != "" {
+		d, exists := dh.definers[source]
+		if !exists {
+			err = fmt.Errorf("unknown source %q", source)
+			return
+		}
+		definers = []Definer{d}
+	} else {
+		dh.mutex.Lock()
+		response = dh.glossaryResponse(term)
+		dh.mutex.Unlock()
+		if response != nil {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	source, definitions, err := define(ctx, definers, term)
+	if err != nil {
 		return
 	}
 
-	replyContent := fmt.Sprintf("Definition(s) for **%s**:", term)
-	for i := 0; i < 3 && i < len(udRes.List); i++ {
-		def := udRes.List[i]
-		replyContent += fmt.Sprintf("\n%d) **%s**: %s ⬆%v / ⬇%v\n%s\n",
-			i+1, def.Word, sanitizeUrbanDictionaryText(def.Definition),
-			def.ThumbsUp, def.ThumbsDown,
-			sanitizeUrbanDictionaryText(def.Example))
+	replyContent := fmt.Sprintf("Definition(s) for **%s** from %s:", term, source)
+	for i := 0; i < 3 && i < len(definitions); i++ {
+		def := definitions[i]
+		replyContent += fmt.Sprintf("\n%d) **%s**: %s", i+1, def.Word, def.Definition)
+		if def.ThumbsUp != 0 || def.ThumbsDown != 0 {
+			replyContent += fmt.Sprintf(" ⬆%v / ⬇%v", def.ThumbsUp, def.ThumbsDown)
+		}
+		replyContent += fmt.Sprintf("\n%s\n", def.Example)
 	}
 
 	response = &api.InteractionResponseData{
-		Content: option.NewNullableString(replyContent),
+		Content: option.NewNullableString(command.Truncate(replyContent, 1999)),
 	}
 
 	return
 }
 
-func sanitizeUrbanDictionaryText(text string) string {
-	return strings.ReplaceAll(strings.ReplaceAll(text, "]", ""), "[", "")
+// define returns the definitions of the first definer that defines the term. Definers failing are
+// skipped, and the error is only returned if none of the others define the term either. Each
+// definer gets at most sourceTimeout, and definers are not tried after the context is done
+func define(ctx context.Context, definers []Definer, term string) (
+	source string, definitions []Definition, err error,
+) {
+	var lastErr error
+	for _, d := range definers {
+		if ctx.Err() != nil {
+			lastErr = fmt.Errorf("%s: not tried in time", d.Name())
+			break
+		}
+
+		sourceCtx, cancel := context.WithTimeout(ctx, sourceTimeout)
+		definitions, err = d.Define(sourceCtx, term)
+		cancel()
+		if err != nil {
+			logrus.Warnf("error occurred defining %q with %s: %v", term, d.Name(), err)
+			lastErr = fmt.Errorf("%s: %v", d.Name(), err)
+			continue
+		}
+
+		if len(definitions) > 0 {
+			return d.Name(), definitions, nil
+		}
+	}
+
+	if lastErr != nil {
+		err = fmt.Errorf("no definition found for the term %s, and a source failed: %v",
+			term, lastErr)
+		return
+	}
+
+	err = fmt.Errorf("no definition returned for the term: %s", term)
+	return
 }
@@ -0,0 +1,113 @@
+package define
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDefiner defines terms with fixed definitions, or fails with err
+type fakeDefiner struct {
+	name        string
+	definitions []Definition
+	err         error
+	delay       time.Duration
+	calls       *int
+}
+
+func (f fakeDefiner) Name() string {
+	return f.name
+}
+
+func (f fakeDefiner) Define(ctx context.Context, _ string) ([]Definition, error) {
+	if f.calls != nil {
+		*f.calls++
+	}
+
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return f.definitions, f.err
+}
+
+func TestDefineFallbackOrder(t *testing.T) {
+	found := []Definition{{Word: "term", Definition: "found"}}
+	thirdCalls := 0
+
+	source, definitions, err := define(context.Background(), []Definer{
+		fakeDefiner{name: "failing", err: errors.New("down")},
+		fakeDefiner{name: "unknowing"},
+		fakeDefiner{name: "defining", definitions: found},
+		fakeDefiner{name: "unused", definitions: found, calls: &thirdCalls},
+	}, "term")
+	if err != nil {
+		t.Fatalf("defining: %v", err)
+	}
+	if source != "defining" || len(definitions) != 1 {
+		t.Errorf("got %d definitions from %q, want the first source defining the term",
+			len(definitions), source)
+	}
+	if thirdCalls != 0 {
+		t.Error("a source after the one defining the term was tried")
+	}
+
+	_, _, err = define(context.Background(), []Definer{
+		fakeDefiner{name: "failing", err: errors.New("down")},
+		fakeDefiner{name: "unknowing"},
+	}, "term")
+	if err == nil || !strings.Contains(err.Error(), "failing: down") {
+		t.Errorf("got error %v, want the failure of the source when none define the term", err)
+	}
+}
+
+func TestDefineTimeouts(t *testing.T) {
+	found := []Definition{{Word: "term", Definition: "found"}}
+
+	start := time.Now()
+	source, _, err := define(context.Background(), []Definer{
+		fakeDefiner{name: "slow", definitions: found, delay: time.Minute},
+		fakeDefiner{name: "fast", definitions: found},
+	}, "term")
+	if err != nil {
+		t.Fatalf("defining: %v", err)
+	}
+	if source != "fast" {
+		t.Errorf("got definitions from %q, want the slow source skipped", source)
+	}
+	if took := time.Since(start); took > sourceTimeout+time.Second {
+		t.Errorf("took %s, want the slow source given up after %s", took, sourceTimeout)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	_, _, err = define(ctx, []Definer{
+		fakeDefiner{name: "late", definitions: found, calls: &calls},
+	}, "term")
+	if err == nil || calls != 0 {
+		t.Errorf("got error %v after %d call(s), want no source tried after the deadline",
+			err, calls)
+	}
+}
+
+func TestDefineNotFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	_, _, err := define(context.Background(), []Definer{
+		urbanDictionary{baseURL: ts.URL},
+		freeDictionary{baseURL: ts.URL},
+		wiktionary{baseURL: ts.URL},
+	}, "asdfgh")
+	if err == nil || !strings.Contains(err.Error(), "no definition returned") {
+		t.Errorf("got error %v, want not found when every source responds 404", err)
+	}
+}
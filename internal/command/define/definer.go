@@ -0,0 +1,77 @@
+package define
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Definition is a definition of a term found by a Definer
+type Definition struct {
+	Word       string
+	Definition string
+	Example    string
+	// ThumbsUp and ThumbsDown are the votes on the definition, for sources that have votes
+	ThumbsUp   int
+	ThumbsDown int
+}
+
+// Definer finds definitions of terms in a source
+type Definer interface {
+	// Name is the name of the source, as used in the config and the source option
+	Name() string
+	// Define returns the definitions of the term, best first. It returns no definitions and no
+	// error if the source does not know the term
+	Define(ctx context.Context, term string) ([]Definition, error)
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// getJSON decodes the JSON response from the URL into v, returning false if there is nothing at
+// the URL
+func getJSON(ctx context.Context, url string, v interface{}) (found bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		err = fmt.Errorf("creating request: %v", err)
+		return
+	}
+
+	req.Header.Set("User-Agent", "lunde")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		err = fmt.Errorf("error contacting define server: %v", err)
+		return
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return
+	}
+
+	bodBytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		err = fmt.Errorf(
+			"failed reading response body from define-server (status was %s): %v",
+			res.Status, err,
+		)
+		return
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		err = fmt.Errorf("define server returned unexpected response code %q", res.Status)
+		return
+	}
+
+	err = json.Unmarshal(bodBytes, v)
+	if err != nil {
+		err = fmt.Errorf("decoding response from define server: %v", err)
+		return
+	}
+
+	return true, nil
+}
@@ -0,0 +1,52 @@
+package define
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+type freeDictionaryResponse []struct {
+	Word     string `json:"word"`
+	Meanings []struct {
+		PartOfSpeech string `json:"partOfSpeech"`
+		Definitions  []struct {
+			Definition string `json:"definition"`
+			Example    string `json:"example"`
+		} `json:"definitions"`
+	} `json:"meanings"`
+}
+
+// freeDictionary defines English words with the Free Dictionary API
+type freeDictionary struct {
+	baseURL string
+}
+
+// Name implements Definer
+func (freeDictionary) Name() string {
+	return "freedictionary"
+}
+
+// Define implements Definer
+func (fd freeDictionary) Define(ctx context.Context, term string) (
+	definitions []Definition, err error,
+) {
+	var entries freeDictionaryResponse
+	found, err := getJSON(ctx, fd.baseURL+"/api/v2/entries/en/"+url.PathEscape(term), &entries)
+	if err != nil || !found {
+		return
+	}
+
+	for _, entry := range entries {
+		for _, meaning := range entry.Meanings {
+			for _, def := range meaning.Definitions {
+				definitions = append(definitions, Definition{
+					Word:       fmt.Sprintf("%s (%s)", entry.Word, meaning.PartOfSpeech),
+					Definition: def.Definition,
+					Example:    def.Example,
+				})
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,54 @@
+package define
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFreeDictionaryDefine(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/entries/en/run" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `[{
+			"word": "run",
+			"meanings": [
+				{"partOfSpeech": "verb", "definitions": [
+					{"definition": "To move swiftly.", "example": "Run to the store."},
+					{"definition": "To flee."}
+				]},
+				{"partOfSpeech": "noun", "definitions": [{"definition": "An act of running."}]}
+			]
+		}]`)
+	}))
+	defer ts.Close()
+
+	fd := freeDictionary{baseURL: ts.URL}
+	definitions, err := fd.Define(context.Background(), "run")
+	if err != nil {
+		t.Fatalf("defining: %v", err)
+	}
+
+	want := []Definition{
+		{Word: "run (verb)", Definition: "To move swiftly.", Example: "Run to the store."},
+		{Word: "run (verb)", Definition: "To flee."},
+		{Word: "run (noun)", Definition: "An act of running."},
+	}
+	if len(definitions) != len(want) {
+		t.Fatalf("got %d definitions, want %d: %+v", len(definitions), len(want), definitions)
+	}
+	for i := range want {
+		if definitions[i] != want[i] {
+			t.Errorf("got definition %d %+v, want %+v", i, definitions[i], want[i])
+		}
+	}
+
+	definitions, err = fd.Define(context.Background(), "asdfgh")
+	if err != nil || len(definitions) != 0 {
+		t.Errorf("got %+v and error %v for an unknown word, want neither", definitions, err)
+	}
+}
@@ -0,0 +1,261 @@
+package define
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/polarbirds/lunde/internal/command"
+)
+
+const (
+	glossaryStoreName = "glossary"
+	// maxGlossaryEntries is how many entries of a term are shown, each with a row of vote buttons
+	maxGlossaryEntries = 3
+)
+
+// glossaryEntry is a definition of a term made by a member of the guild
+type glossaryEntry struct {
+	ID         int            `json:"id"`
+	Term       string         `json:"term"`
+	Definition string         `json:"definition"`
+	AuthorID   discord.UserID `json:"authorID"`
+	Created    time.Time      `json:"created"`
+	// Votes holds the vote of each member voting, 1 for up and -1 for down
+	Votes map[discord.UserID]int `json:"votes"`
+}
+
+type glossary struct {
+	NextID  int              `json:"nextID"`
+	Entries []*glossaryEntry `json:"entries"`
+}
+
+func (e *glossaryEntry) count(vote int) (n int) {
+	for _, v := range e.Votes {
+		if v == vote {
+			n++
+		}
+	}
+	return
+}
+
+func (e *glossaryEntry) score() int {
+	return e.count(1) - e.count(-1)
+}
+
+func (dh *defineHandler) loadGlossary() error {
+	err := dh.srv.Store.Load(glossaryStoreName, &dh.glossary)
+	if err != nil {
+		return fmt.Errorf("loading glossary: %v", err)
+	}
+	return nil
+}
+
+// saveGlossary persists the glossary, and must be called with the mutex held
+func (dh *defineHandler) saveGlossary() error {
+	err := dh.srv.Store.Save(glossaryStoreName, dh.glossary)
+	if err != nil {
+		return fmt.Errorf("saving glossary: %v", err)
+	}
+	return nil
+}
+
+func (dh *defineHandler) addEntry(
+	event *gateway.InteractionCreateEvent,
+	options map[string]discord.CommandInteractionOption,
+) (
+	msg string, err error,
+) {
+	entry := &glossaryEntry{
+		Term:       strings.TrimSpace(options["term"].String()),
+		Definition: strings.TrimSpace(options["definition"].String()),
+		AuthorID:   event.Member.User.ID,
+		Created:    time.Now(),
+		Votes:      map[discord.UserID]int{},
+	}
+	if entry.Term == "" || entry.Definition == "" {
+		err = errors.New("both a term and a definition must be given")
+		return
+	}
+
+	dh.mutex.Lock()
+	defer dh.mutex.Unlock()
+
+	entry.ID = dh.glossary.NextID
+	dh.glossary.NextID++
+	dh.glossary.Entries = append(dh.glossary.Entries, entry)
+	err = dh.saveGlossary()
+	if err != nil {
+		return
+	}
+
+	msg = fmt.Sprintf("added **%s** to the glossary as entry %d", entry.Term, entry.ID)
+	return
+}
+
+// removeEntry removes an entry from the glossary. Members can remove their own entries, and
+// members who can manage messages can remove any entry
+func (dh *defineHandler) removeEntry(
+	event *gateway.InteractionCreateEvent,
+	options map[string]discord.CommandInteractionOption,
+) (
+	msg string, err error,
+) {
+	id, err := options["id"].IntValue()
+	if err != nil {
+		err = fmt.Errorf("parsing id as int: %v", err)
+		return
+	}
+
+	dh.mutex.Lock()
+	defer dh.mutex.Unlock()
+
+	for i, entry := range dh.glossary.Entries {
+		if int64(entry.ID) != id {
+			continue
+		}
+
+		if entry.AuthorID != event.Member.User.ID {
+			err = command.RequirePermission(dh.srv.Session, event, discord.PermissionManageMessages)
+			if err != nil {
+				return
+			}
+		}
+
+		entries := dh.glossary.Entries
+		dh.glossary.Entries = append(entries[:i], entries[i+1:]...)
+		err = dh.saveGlossary()
+		if err != nil {
+			return
+		}
+
+		msg = fmt.Sprintf("removed glossary entry %d for **%s**", entry.ID, entry.Term)
+		return
+	}
+
+	err = fmt.Errorf("found no glossary entry with ID %d", id)
+	return
+}
+
+// entriesFor returns the entries of the term, the best voted first, and must be called with the
+// mutex held
+func (dh *defineHandler) entriesFor(term string) []*glossaryEntry {
+	entries := []*glossaryEntry{}
+	for _, entry := range dh.glossary.Entries {
+		if strings.EqualFold(entry.Term, strings.TrimSpace(term)) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].score() > entries[j].score()
+	})
+	return entries
+}
+
+// glossaryResponse lists the glossary entries of the term with buttons to vote on them, or returns
+// nil if the term is not in the glossary. It must be called with the mutex held
+func (dh *defineHandler) glossaryResponse(term string) *api.InteractionResponseData {
+	entries := dh.entriesFor(term)
+	if len(entries) == 0 {
+		return nil
+	}
+
+	content := fmt.Sprintf("Glossary entries for **%s**:", entries[0].Term)
+	rows := discord.ContainerComponents{}
+	for i, entry := range entries {
+		if i == maxGlossaryEntries {
+			break
+		}
+
+		content += fmt.Sprintf("\n%d) %s\n*by %s <t:%d:d>, entry %d*\n",
+			i+1, entry.Definition, entry.AuthorID.Mention(), entry.Created.Unix(), entry.ID)
+
+		rows = append(rows, &discord.ActionRowComponent{
+			voteButton(entry, i+1, 1),
+			voteButton(entry, i+1, -1),
+		})
+	}
+
+	return &api.InteractionResponseData{
+		Content:         option.NewNullableString(command.Truncate(content, 1999)),
+		Components:      &rows,
+		AllowedMentions: &api.AllowedMentions{},
+	}
+}
+
+func voteButton(entry *glossaryEntry, number int, vote int) *discord.ButtonComponent {
+	arrow := "⬆"
+	if vote < 0 {
+		arrow = "⬇"
+	}
+
+	return &discord.ButtonComponent{
+		Style:    discord.SecondaryButtonStyle(),
+		Label:    fmt.Sprintf("%d) %s%d", number, arrow, entry.count(vote)),
+		CustomID: command.ComponentID("define", fmt.Sprintf("vote:%d:%d", entry.ID, vote)),
+	}
+}
+
+// handleComponent handles the vote buttons of glossary entries. Voting the same way twice takes
+// the vote back
+func (dh *defineHandler) handleComponent(event *gateway.InteractionCreateEvent, customID string) (
+	response *api.InteractionResponseData, err error,
+) {
+	parts := strings.Split(customID, ":")
+	if len(parts) != 3 || parts[0] != "vote" {
+		err = fmt.Errorf("unknown define component %q", customID)
+		return
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		err = fmt.Errorf("parsing entry ID of component %q: %v", customID, err)
+		return
+	}
+	vote, err := strconv.Atoi(parts[2])
+	if err != nil || (vote != 1 && vote != -1) {
+		err = fmt.Errorf("parsing vote of component %q", customID)
+		return
+	}
+
+	dh.mutex.Lock()
+	defer dh.mutex.Unlock()
+
+	var entry *glossaryEntry
+	for _, e := range dh.glossary.Entries {
+		if e.ID == id {
+			entry = e
+			break
+		}
+	}
+	if entry == nil {
+		err = fmt.Errorf("glossary entry %d no longer exists", id)
+		return
+	}
+
+	if entry.Votes == nil {
+		entry.Votes = map[discord.UserID]int{}
+	}
+
+	userID := event.Member.User.ID
+	if entry.Votes[userID] == vote {
+		delete(entry.Votes, userID)
+	} else {
+		entry.Votes[userID] = vote
+	}
+
+	err = dh.saveGlossary()
+	if err != nil {
+		return
+	}
+
+	return dh.glossaryResponse(entry.Term), nil
+}
@@ -0,0 +1,53 @@
+package define
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+type udResponse struct {
+	List []struct {
+		Word       string `json:"word"`
+		Definition string `json:"definition"`
+		Example    string `json:"example"`
+		ThumbsUp   int    `json:"thumbs_up"`
+		ThumbsDown int    `json:"thumbs_down"`
+	} `json:"list"`
+}
+
+// urbanDictionary defines terms with the Urban Dictionary API
+type urbanDictionary struct {
+	baseURL string
+}
+
+// Name implements Definer
+func (urbanDictionary) Name() string {
+	return "urbandictionary"
+}
+
+// Define implements Definer
+func (ud urbanDictionary) Define(ctx context.Context, term string) (
+	definitions []Definition, err error,
+) {
+	var udRes udResponse
+	found, err := getJSON(ctx, ud.baseURL+"/v0/define?term="+url.QueryEscape(term), &udRes)
+	if err != nil || !found {
+		return
+	}
+
+	for _, def := range udRes.List {
+		definitions = append(definitions, Definition{
+			Word:       def.Word,
+			Definition: sanitizeUrbanDictionaryText(def.Definition),
+			Example:    sanitizeUrbanDictionaryText(def.Example),
+			ThumbsUp:   def.ThumbsUp,
+			ThumbsDown: def.ThumbsDown,
+		})
+	}
+	return
+}
+
+func sanitizeUrbanDictionaryText(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "]", ""), "[", "")
+}
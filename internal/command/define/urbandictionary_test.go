@@ -0,0 +1,43 @@
+package define
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUrbanDictionaryDefine(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/define" || r.URL.Query().Get("term") != "yeet it" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"list": [{
+			"word": "yeet it",
+			"definition": "To [throw] something with [force].",
+			"example": "[Yeet it] into the sea.",
+			"thumbs_up": 10,
+			"thumbs_down": 2
+		}]}`)
+	}))
+	defer ts.Close()
+
+	ud := urbanDictionary{baseURL: ts.URL}
+	definitions, err := ud.Define(context.Background(), "yeet it")
+	if err != nil {
+		t.Fatalf("defining: %v", err)
+	}
+
+	want := Definition{
+		Word:       "yeet it",
+		Definition: "To throw something with force.",
+		Example:    "Yeet it into the sea.",
+		ThumbsUp:   10,
+		ThumbsDown: 2,
+	}
+	if len(definitions) != 1 || definitions[0] != want {
+		t.Errorf("got definitions %+v, want %+v", definitions, want)
+	}
+}
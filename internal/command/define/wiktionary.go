@@ -0,0 +1,81 @@
+package define
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// wiktionaryResponse holds the usages of a term by language code
+type wiktionaryResponse map[string][]struct {
+	PartOfSpeech string `json:"partOfSpeech"`
+	Language     string `json:"language"`
+	Definitions  []struct {
+		Definition string   `json:"definition"`
+		Examples   []string `json:"examples"`
+	} `json:"definitions"`
+}
+
+// wiktionary defines terms with the definition API of a Wikimedia wiktionary
+type wiktionary struct {
+	baseURL string
+}
+
+// Name implements Definer
+func (wiktionary) Name() string {
+	return "wiktionary"
+}
+
+// Define implements Definer. English definitions come first, then the other languages by code
+func (w wiktionary) Define(ctx context.Context, term string) (
+	definitions []Definition, err error,
+) {
+	var res wiktionaryResponse
+	found, err := getJSON(ctx, w.baseURL+"/api/rest_v1/page/definition/"+url.PathEscape(term), &res)
+	if err != nil || !found {
+		return
+	}
+
+	languages := make([]string, 0, len(res))
+	for language := range res {
+		languages = append(languages, language)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if (languages[i] == "en") != (languages[j] == "en") {
+			return languages[i] == "en"
+		}
+		return languages[i] < languages[j]
+	})
+
+	for _, language := range languages {
+		for _, usage := range res[language] {
+			for _, def := range usage.Definitions {
+				text := stripHTML(def.Definition)
+				if text == "" {
+					continue
+				}
+
+				definition := Definition{
+					Word: fmt.Sprintf("%s (%s, %s)", term, usage.Language,
+						strings.ToLower(usage.PartOfSpeech)),
+					Definition: text,
+				}
+				if len(def.Examples) > 0 {
+					definition.Example = stripHTML(def.Examples[0])
+				}
+				definitions = append(definitions, definition)
+			}
+		}
+	}
+	return
+}
+
+func stripHTML(text string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(text, "")))
+}
@@ -0,0 +1,52 @@
+package define
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWiktionaryDefine(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/rest_v1/page/definition/hund" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{
+			"no": [{"partOfSpeech": "Noun", "language": "Norwegian", "definitions": [
+				{"definition": "<a href=\"/wiki/dog\">dog</a>", "examples": ["<i>en</i> hund"]}
+			]}],
+			"en": [{"partOfSpeech": "Noun", "language": "English", "definitions": [
+				{"definition": "<span>A</span> hound &amp; dog."},
+				{"definition": "<span></span>"}
+			]}],
+			"de": [{"partOfSpeech": "Noun", "language": "German", "definitions": [
+				{"definition": "dog"}
+			]}]
+		}`)
+	}))
+	defer ts.Close()
+
+	w := wiktionary{baseURL: ts.URL}
+	definitions, err := w.Define(context.Background(), "hund")
+	if err != nil {
+		t.Fatalf("defining: %v", err)
+	}
+
+	// English first, then by language code, and definitions left empty by stripping are skipped
+	want := []Definition{
+		{Word: "hund (English, noun)", Definition: "A hound & dog."},
+		{Word: "hund (German, noun)", Definition: "dog"},
+		{Word: "hund (Norwegian, noun)", Definition: "dog", Example: "en hund"},
+	}
+	if len(definitions) != len(want) {
+		t.Fatalf("got %d definitions, want %d: %+v", len(definitions), len(want), definitions)
+	}
+	for i := range want {
+		if definitions[i] != want[i] {
+			t.Errorf("got definition %d %+v, want %+v", i, definitions[i], want[i])
+		}
+	}
+}
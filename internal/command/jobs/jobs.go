@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/polarbirds/lunde/internal/command"
+	"github.com/polarbirds/lunde/internal/server"
+	"github.com/sirupsen/logrus"
+)
+
+type jobsHandler struct {
+	srv *server.Server
+}
+
+// CreateCommand creates a lunde command for admins to inspect and control the scheduled jobs
+func CreateCommand(srv *server.Server) (cmd command.LundeCommand, err error) {
+	jh := jobsHandler{srv: srv}
+
+	jobOption := func() []discord.CommandOptionValue {
+		return []discord.CommandOptionValue{
+			&discord.StringOption{
+				OptionName:  "job",
+				Description: "name of the job, as shown by /jobs list",
+				Required:    true,
+			},
+		}
+	}
+
+	cmd = command.LundeCommand{
+		HandleInteraction: jh.handleInteraction,
+		CommandData: api.CreateCommandData{
+			Name:                     "jobs",
+			Description:              "manage the scheduled jobs",
+			DefaultMemberPermissions: discord.NewPermissions(discord.PermissionAdministrator),
+			Options: []discord.CommandOption{
+				&discord.SubcommandOption{
+					OptionName:  "list",
+					Description: "list the scheduled jobs and their status",
+				},
+				&discord.SubcommandOption{
+					OptionName:  "run",
+					Description: "run a job now",
+					Options:     jobOption(),
+				},
+				&discord.SubcommandOption{
+					OptionName:  "pause",
+					Description: "stop running a job on its schedule",
+					Options:     jobOption(),
+				},
+				&discord.SubcommandOption{
+					OptionName:  "resume",
+					Description: "run a paused job on its schedule again",
+					Options:     jobOption(),
+				},
+			},
+		},
+	}
+
+	return
+}
+
+func (jh *jobsHandler) handleInteraction(
+	_ *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	subcommand, subOptions, err := command.Subcommand(options)
+	if err != nil {
+		return
+	}
+
+	name := subOptions["job"].String()
+
+	var msg string
+	switch subcommand {
+	case "list":
+		msg = jh.list()
+	case "run":
+		msg, err = jh.run(name)
+	case "pause":
+		err = jh.srv.Scheduler.Pause(name)
+		msg = fmt.Sprintf("paused job %q", name)
+	case "resume":
+		err = jh.srv.Scheduler.Resume(name)
+		msg = fmt.Sprintf("resumed job %q", name)
+	default:
+		err = fmt.Errorf("unknown subcommand %q", subcommand)
+	}
+	if err != nil {
+		return
+	}
+
+	response = &api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+	}
+	return
+}
+
+func (jh *jobsHandler) run(name string) (msg string, err error) {
+	found := false
+	for _, status := range jh.srv.Scheduler.Jobs() {
+		found = found || status.Name == name
+	}
+	if !found {
+		err = fmt.Errorf("found no job named %q", name)
+		return
+	}
+
+	// jobs can take longer than discord waits for a response, their errors show in /jobs list
+	go func() {
+		runErr := jh.srv.Scheduler.Run(name)
+		if runErr != nil {
+			logrus.Errorf("error occurred running job %q manually: %v", name, runErr)
+		}
+	}()
+
+	msg = fmt.Sprintf("running job %q", name)
+	return
+}
+
+func (jh *jobsHandler) list() string {
+	statuses := jh.srv.Scheduler.Jobs()
+	if len(statuses) == 0 {
+		return "there are no scheduled jobs"
+	}
+
+	lines := []string{}
+	for _, status := range statuses {
+		line := fmt.Sprintf("**%s** - %s\nlast run %s, next run %s",
+			status.Name, status.Description, timestamp(status.LastRun), timestamp(status.NextRun))
+		if status.Paused {
+			line += ", **paused**"
+		}
+		if status.LastError != "" {
+			line += fmt.Sprintf("\nlast error: `%s`", status.LastError)
+		}
+		lines = append(lines, line)
+	}
+
+	msg := command.Truncate(strings.Join(lines, "\n"), 1999)
+	return msg
+}
+
+// timestamp formats the time as a discord timestamp shown relative to now in the user's timezone
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("<t:%d:R>", t.Unix())
+}
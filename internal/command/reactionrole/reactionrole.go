@@ -0,0 +1,408 @@
+package reactionrole
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/polarbirds/lunde/internal/command"
+	"github.com/polarbirds/lunde/internal/server"
+	"github.com/sirupsen/logrus"
+)
+
+const storeName = "reactionroles"
+
+var messageLinkPattern = regexp.MustCompile(`channels/\d+/(\d+)/(\d+)`)
+
+// binding grants RoleID to users reacting with Emoji on the message MessageID
+type binding struct {
+	ChannelID discord.ChannelID `json:"channelID"`
+	MessageID discord.MessageID `json:"messageID"`
+	Emoji     discord.APIEmoji  `json:"emoji"`
+	RoleID    discord.RoleID    `json:"roleID"`
+}
+
+type reactionRoleHandler struct {
+	srv *server.Server
+	// botID is the ID of the bot, whose own reactions do not grant or remove roles
+	botID    discord.UserID
+	bindings []binding
+	mutex    sync.RWMutex
+}
+
+// CreateCommand creates a lunde command to manage roles granted by reacting to messages
+func CreateCommand(srv *server.Server) (cmd command.LundeCommand, err error) {
+	rh := &reactionRoleHandler{srv: srv}
+	err = srv.Store.Load(storeName, &rh.bindings)
+	if err != nil {
+		err = fmt.Errorf("loading reaction roles: %v", err)
+		return
+	}
+
+	me, err := srv.Session.Me()
+	if err != nil {
+		err = fmt.Errorf("getting bot user: %v", err)
+		return
+	}
+	rh.botID = me.ID
+
+	srv.AddReactionAddHandler(rh.handleReactionAdd)
+	srv.AddReactionRemoveHandler(rh.handleReactionRemove)
+
+	messageOption := &discord.StringOption{
+		OptionName:  "message",
+		Description: "link to or ID of the message, IDs are looked up in this channel",
+		Required:    true,
+	}
+	emojiOption := &discord.StringOption{
+		OptionName:  "emoji",
+		Description: "emoji to react with",
+		Required:    true,
+	}
+
+	cmd = command.LundeCommand{
+		HandleInteraction: rh.handleInteraction,
+		CommandData: api.CreateCommandData{
+			Name:                     "reactionrole",
+			Description:              "grant roles to users reacting to a message",
+			DefaultMemberPermissions: discord.NewPermissions(discord.PermissionManageRoles),
+			Options: []discord.CommandOption{
+				&discord.SubcommandOption{
+					OptionName:  "add",
+					Description: "grant a role when reacting with an emoji on a message",
+					Options: []discord.CommandOptionValue{
+						messageOption,
+						emojiOption,
+						&discord.RoleOption{
+							OptionName:  "role",
+							Description: "role to grant",
+							Required:    true,
+						},
+					},
+				},
+				&discord.SubcommandOption{
+					OptionName:  "remove",
+					Description: "stop granting a role for an emoji on a message",
+					Options:     []discord.CommandOptionValue{messageOption, emojiOption},
+				},
+				&discord.SubcommandOption{
+					OptionName:  "list",
+					Description: "list all reaction roles",
+				},
+			},
+		},
+	}
+
+	return
+}
+
+func (rh *reactionRoleHandler) handleInteraction(
+	event *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	subcommand, subOptions, err := command.Subcommand(options)
+	if err != nil {
+		return
+	}
+
+	var msg string
+	switch subcommand {
+	case "add":
+		msg, err = rh.add(event, subOptions)
+	case "remove":
+		msg, err = rh.remove(event, subOptions)
+	case "list":
+		msg = rh.list()
+	default:
+		err = fmt.Errorf("unknown subcommand %q", subcommand)
+	}
+	if err != nil {
+		return
+	}
+
+	response = &api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+	}
+	return
+}
+
+func (rh *reactionRoleHandler) add(
+	event *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	msg string, err error,
+) {
+	b, err := parseBinding(event, options)
+	if err != nil {
+		return
+	}
+
+	roleFlake, err := options["role"].SnowflakeValue()
+	if err != nil {
+		err = fmt.Errorf("parsing role as flake: %v", err)
+		return
+	}
+	b.RoleID = discord.RoleID(roleFlake)
+
+	err = rh.checkGrantable(event, b.RoleID)
+	if err != nil {
+		return
+	}
+
+	// reacting on the message both verifies that it exists and shows users what to react with
+	err = rh.srv.Session.React(b.ChannelID, b.MessageID, b.Emoji)
+	if err != nil {
+		err = fmt.Errorf("reacting with %s to message %s: %v", b.Emoji, b.MessageID, err)
+		return
+	}
+
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	for i, existing := range rh.bindings {
+		if existing.MessageID == b.MessageID && existing.Emoji == b.Emoji {
+			rh.bindings = append(rh.bindings[:i], rh.bindings[i+1:]...)
+			break
+		}
+	}
+	rh.bindings = append(rh.bindings, b)
+
+	err = rh.srv.Store.Save(storeName, rh.bindings)
+	if err != nil {
+		err = fmt.Errorf("saving reaction roles: %v", err)
+		return
+	}
+
+	msg = fmt.Sprintf("reacting with %s on %s now grants %s",
+		command.EmojiString(b.Emoji), messageLink(rh.srv.GuildID, b), b.RoleID.Mention())
+	return
+}
+
+// checkGrantable returns an error unless the role is below the highest role of both the invoker
+// and the bot, so members cannot bind roles they could not grant themselves to get them by
+// reacting
+func (rh *reactionRoleHandler) checkGrantable(
+	event *gateway.InteractionCreateEvent, roleID discord.RoleID,
+) error {
+	guild, err := rh.srv.Session.Guild(rh.srv.GuildID)
+	if err != nil {
+		return fmt.Errorf("getting guild: %v", err)
+	}
+
+	roles, err := rh.srv.Session.Roles(rh.srv.GuildID)
+	if err != nil {
+		return fmt.Errorf("getting roles: %v", err)
+	}
+
+	bot, err := rh.srv.Session.Member(rh.srv.GuildID, rh.botID)
+	if err != nil {
+		return fmt.Errorf("getting bot member: %v", err)
+	}
+
+	return grantable(roles, roleID, event.Member.RoleIDs, bot.RoleIDs,
+		event.Member.User.ID == guild.OwnerID)
+}
+
+// grantable returns an error unless the role is below the highest of both the invoker's and the
+// bot's roles. The owner of the guild is only limited by the roles of the bot
+func grantable(
+	roles []discord.Role, roleID discord.RoleID, invokerRoles []discord.RoleID,
+	botRoles []discord.RoleID, invokerIsOwner bool,
+) error {
+	positions := make(map[discord.RoleID]int, len(roles))
+	for _, role := range roles {
+		positions[role.ID] = role.Position
+	}
+
+	position, exists := positions[roleID]
+	if !exists {
+		return fmt.Errorf("found no role %s", roleID)
+	}
+
+	highest := func(roleIDs []discord.RoleID) (highest int) {
+		for _, id := range roleIDs {
+			if positions[id] > highest {
+				highest = positions[id]
+			}
+		}
+		return
+	}
+
+	if !invokerIsOwner && position >= highest(invokerRoles) {
+		return fmt.Errorf("%s is not below your highest role", roleID.Mention())
+	}
+	if position >= highest(botRoles) {
+		return fmt.Errorf("%s is not below the highest role of the bot", roleID.Mention())
+	}
+	return nil
+}
+
+func (rh *reactionRoleHandler) remove(
+	event *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	msg string, err error,
+) {
+	b, err := parseBinding(event, options)
+	if err != nil {
+		return
+	}
+
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	for i, existing := range rh.bindings {
+		if existing.MessageID != b.MessageID || existing.Emoji != b.Emoji {
+			continue
+		}
+
+		rh.bindings = append(rh.bindings[:i], rh.bindings[i+1:]...)
+		err = rh.srv.Store.Save(storeName, rh.bindings)
+		if err != nil {
+			err = fmt.Errorf("saving reaction roles: %v", err)
+			return
+		}
+
+		// the reaction of the bot would otherwise invite members to react for nothing. The message
+		// may be deleted, so failing to remove it does not fail removing the binding
+		err = rh.srv.Session.Unreact(existing.ChannelID, existing.MessageID, existing.Emoji)
+		if err != nil {
+			logrus.Warnf("error occurred removing reaction %s from message %s: %v",
+				existing.Emoji, existing.MessageID, err)
+			err = nil
+		}
+
+		msg = fmt.Sprintf("reacting with %s on %s no longer grants %s",
+			command.EmojiString(b.Emoji), messageLink(rh.srv.GuildID, existing),
+			existing.RoleID.Mention())
+		return
+	}
+
+	err = fmt.Errorf("no reaction role exists for %s on message %s", b.Emoji, b.MessageID)
+	return
+}
+
+func (rh *reactionRoleHandler) list() string {
+	rh.mutex.RLock()
+	defer rh.mutex.RUnlock()
+
+	if len(rh.bindings) == 0 {
+		return "no reaction roles are set up"
+	}
+
+	lines := []string{"Reaction roles:"}
+	for _, b := range rh.bindings {
+		lines = append(lines, fmt.Sprintf("%s on %s grants %s",
+			command.EmojiString(b.Emoji), messageLink(rh.srv.GuildID, b), b.RoleID.Mention()))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (rh *reactionRoleHandler) handleReactionAdd(ev *gateway.MessageReactionAddEvent) {
+	if ev.UserID == rh.botID || (ev.Member != nil && ev.Member.User.Bot) {
+		return
+	}
+
+	roleID, exists := rh.roleFor(ev.MessageID, ev.Emoji)
+	if !exists {
+		return
+	}
+
+	err := rh.srv.Session.AddRole(ev.GuildID, ev.UserID, roleID, api.AddRoleData{
+		AuditLogReason: "reaction role",
+	})
+	if err != nil {
+		logrus.Errorf("error occurred adding role %s to user %s: %v", roleID, ev.UserID, err)
+		return
+	}
+	logrus.Infof("added role %s to user %s by reaction", roleID, ev.UserID)
+}
+
+func (rh *reactionRoleHandler) handleReactionRemove(ev *gateway.MessageReactionRemoveEvent) {
+	// removal events have no member to tell whether a bot reacted, so only the own reactions of
+	// the bot are known to not be from a member
+	if ev.UserID == rh.botID {
+		return
+	}
+
+	roleID, exists := rh.roleFor(ev.MessageID, ev.Emoji)
+	if !exists {
+		return
+	}
+
+	err := rh.srv.Session.RemoveRole(ev.GuildID, ev.UserID, roleID, "reaction role")
+	if err != nil {
+		logrus.Errorf("error occurred removing role %s from user %s: %v", roleID, ev.UserID, err)
+		return
+	}
+	logrus.Infof("removed role %s from user %s by reaction", roleID, ev.UserID)
+}
+
+func (rh *reactionRoleHandler) roleFor(
+	messageID discord.MessageID, emoji discord.Emoji,
+) (discord.RoleID, bool) {
+	rh.mutex.RLock()
+	defer rh.mutex.RUnlock()
+
+	for _, b := range rh.bindings {
+		if b.MessageID == messageID && b.Emoji == emoji.APIString() {
+			return b.RoleID, true
+		}
+	}
+
+	return 0, false
+}
+
+// parseBinding parses the message and emoji options into a binding without a role
+func parseBinding(
+	event *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	b binding, err error,
+) {
+	b.Emoji, err = command.ParseEmoji(options["emoji"].String())
+	if err != nil {
+		err = fmt.Errorf("parsing emoji: %v", err)
+		return
+	}
+
+	b.ChannelID, b.MessageID, err = parseMessage(options["message"].String(), event.ChannelID)
+	if err != nil {
+		err = fmt.Errorf("parsing message: %v", err)
+		return
+	}
+
+	return
+}
+
+// parseMessage parses a message link, or a message ID in the given channel
+func parseMessage(text string, channelID discord.ChannelID) (
+	discord.ChannelID, discord.MessageID, error,
+) {
+	text = strings.TrimSpace(text)
+	if match := messageLinkPattern.FindStringSubmatch(text); match != nil {
+		chanFlake, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parsing channel ID in link: %v", err)
+		}
+		channelID = discord.ChannelID(chanFlake)
+		text = match[2]
+	}
+
+	msgFlake, err := strconv.ParseUint(text, 10, 64)
+	if err != nil || msgFlake == 0 {
+		return 0, 0, errors.New("expected a message link or a message ID")
+	}
+
+	return channelID, discord.MessageID(msgFlake), nil
+}
+
+func messageLink(guildID discord.GuildID, b binding) string {
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, b.ChannelID, b.MessageID)
+}
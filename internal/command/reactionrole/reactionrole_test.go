@@ -0,0 +1,49 @@
+package reactionrole
+
+import (
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestGrantable(t *testing.T) {
+	roles := []discord.Role{
+		{ID: 1, Position: 0}, // @everyone
+		{ID: 2, Position: 1},
+		{ID: 3, Position: 2},
+		{ID: 4, Position: 3},
+		{ID: 5, Position: 4},
+	}
+
+	tests := []struct {
+		name         string
+		role         discord.RoleID
+		invokerRoles []discord.RoleID
+		botRoles     []discord.RoleID
+		owner        bool
+		wantErr      bool
+	}{
+		{name: "below both", role: 2, invokerRoles: []discord.RoleID{3}, botRoles: []discord.RoleID{4}},
+		{name: "highest of several roles counts", role: 3, invokerRoles: []discord.RoleID{2, 4},
+			botRoles: []discord.RoleID{5}},
+		{name: "equal to the invoker's", role: 3, invokerRoles: []discord.RoleID{3},
+			botRoles: []discord.RoleID{5}, wantErr: true},
+		{name: "above the invoker's", role: 5, invokerRoles: []discord.RoleID{2},
+			botRoles: []discord.RoleID{5}, wantErr: true},
+		{name: "invoker without roles", role: 2, botRoles: []discord.RoleID{5}, wantErr: true},
+		{name: "above the bot's", role: 4, invokerRoles: []discord.RoleID{5},
+			botRoles: []discord.RoleID{3}, wantErr: true},
+		{name: "owner above own roles", role: 4, botRoles: []discord.RoleID{5}, owner: true},
+		{name: "owner above the bot's", role: 5, botRoles: []discord.RoleID{4}, owner: true,
+			wantErr: true},
+		{name: "unknown role", role: 9, invokerRoles: []discord.RoleID{5},
+			botRoles: []discord.RoleID{5}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := grantable(roles, tt.role, tt.invokerRoles, tt.botRoles, tt.owner)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
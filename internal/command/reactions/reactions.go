@@ -0,0 +1,123 @@
+package reactions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/polarbirds/lunde/internal/command"
+	"github.com/polarbirds/lunde/internal/server"
+	"github.com/polarbirds/lunde/internal/stats"
+)
+
+var periods = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+type reactionsHandler struct {
+	srv *server.Server
+}
+
+// CreateCommand creates a lunde command showing statistics of reactions to messages
+func CreateCommand(srv *server.Server) (cmd command.LundeCommand, err error) {
+	rh := reactionsHandler{srv}
+
+	cmd = command.LundeCommand{
+		HandleInteraction: rh.handleInteraction,
+		CommandData: api.CreateCommandData{
+			Name:        "reactions",
+			Description: "show statistics of reactions",
+			Options: []discord.CommandOption{
+				&discord.SubcommandOption{
+					OptionName:  "top",
+					Description: "show the most reacted messages, givers, receivers and emojis",
+					Options: []discord.CommandOptionValue{
+						&discord.StringOption{
+							OptionName:  "period",
+							Description: "how far back to look, defaults to all kept reactions",
+							Choices: []discord.StringChoice{
+								{Name: "day", Value: "day"},
+								{Name: "week", Value: "week"},
+								{Name: "month", Value: "month"},
+								{Name: "all", Value: "all"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return
+}
+
+func (rh *reactionsHandler) handleInteraction(
+	_ *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	subcommand, subOptions, err := command.Subcommand(options)
+	if err != nil {
+		return
+	}
+
+	if subcommand != "top" {
+		err = fmt.Errorf("unknown subcommand %q", subcommand)
+		return
+	}
+
+	period := subOptions["period"].String()
+	var since time.Time
+	if d, exists := periods[period]; exists {
+		since = time.Now().Add(-d)
+	} else {
+		period = "all time"
+	}
+
+	embed := TopEmbed(rh.srv, since)
+	embed.Title = fmt.Sprintf("Reaction top list, %s", period)
+	response = &api.InteractionResponseData{
+		Embeds: &[]discord.Embed{embed},
+	}
+
+	return
+}
+
+// TopEmbed builds an embed with the most reacted messages, the top givers and receivers of
+// reactions, and the most used emojis among reactions given since the given time
+func TopEmbed(srv *server.Server, since time.Time) discord.Embed {
+	givers := map[string]int{}
+	receivers := map[string]int{}
+	emojis := map[string]int{}
+
+	srv.ReactionMutex.RLock()
+	for _, records := range srv.ReactionData {
+		for _, rec := range records {
+			if rec.Time.Before(since) {
+				continue
+			}
+
+			givers[rec.UserID.Mention()]++
+			receivers[rec.AuthorID.Mention()]++
+			emojis[command.EmojiString(rec.Emoji)]++
+		}
+	}
+	srv.ReactionMutex.RUnlock()
+
+	if len(givers) == 0 {
+		return discord.Embed{Description: "no reactions found"}
+	}
+
+	return discord.Embed{
+		Fields: []discord.EmbedField{
+			{Name: "Most reacted messages", Value: stats.MostReacted(srv, since)},
+			{Name: "Top givers", Value: stats.TopLines(givers), Inline: true},
+			{Name: "Top receivers", Value: stats.TopLines(receivers), Inline: true},
+			{Name: "Top emojis", Value: stats.TopLines(emojis), Inline: true},
+		},
+	}
+}
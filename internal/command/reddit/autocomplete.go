@@ -0,0 +1,149 @@
+package reddit
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/polarbirds/lunde/internal/command"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	usedSubredditsStoreName = "redditsubreddits"
+	// maxSuggestions is how many choices discord shows when autocompleting
+	maxSuggestions = 25
+	// redditSuggestionCount is how many subreddits reddit is asked to suggest
+	redditSuggestionCount = 10
+)
+
+func (rh *redditHandler) loadUsedSubreddits() error {
+	rh.usedSubreddits = map[string]int{}
+	err := rh.srv.Store.Load(usedSubredditsStoreName, &rh.usedSubreddits)
+	if err != nil {
+		return fmt.Errorf("loading used subreddits: %v", err)
+	}
+	return nil
+}
+
+// recordSubreddit counts a use of the subreddit, so it is suggested before the ones used less
+func (rh *redditHandler) recordSubreddit(subreddit string) {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	rh.usedSubreddits[strings.ToLower(subreddit)]++
+	err := rh.srv.Store.Save(usedSubredditsStoreName, rh.usedSubreddits)
+	if err != nil {
+		logrus.Errorf("error occurred saving used subreddits: %v", err)
+	}
+}
+
+// handleAutocomplete suggests subreddits for the sub options, first the ones used before and then
+// the ones reddit suggests
+func (rh *redditHandler) handleAutocomplete(
+	event *gateway.InteractionCreateEvent, options discord.AutocompleteOptions,
+) (
+	api.AutocompleteChoices, error,
+) {
+	focused := command.FocusedOption(options)
+	if focused.Name != "sub" {
+		return nil, nil
+	}
+
+	prefix := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(focused.String())), "r/")
+	suggested := map[string]bool{}
+	choices := api.AutocompleteStringChoices{}
+	suggest := func(subreddit string) {
+		if len(choices) == maxSuggestions || suggested[strings.ToLower(subreddit)] ||
+			rh.content.checkSubreddit(subreddit) != nil {
+			return
+		}
+		suggested[strings.ToLower(subreddit)] = true
+		choices = append(choices, discord.StringChoice{Name: "r/" + subreddit, Value: subreddit})
+	}
+
+	for _, subreddit := range rh.usedSubredditsByUse() {
+		if strings.HasPrefix(subreddit, prefix) {
+			suggest(subreddit)
+		}
+	}
+
+	if prefix == "" {
+		return choices, nil
+	}
+
+	nsfw := false
+	if ch, err := rh.srv.Session.Channel(event.ChannelID); err == nil {
+		nsfw = ch.NSFW
+	}
+
+	// the subreddits used before are suggested alone if reddit fails, as discord only shows
+	// suggestions given in time
+	subreddits, err := rh.interactionClient.subredditSuggestions(prefix, nsfw)
+	if err != nil {
+		logrus.Warnf("error occurred getting subreddit suggestions for %q: %v", prefix, err)
+		return choices, nil
+	}
+	for _, subreddit := range subreddits {
+		suggest(subreddit)
+	}
+
+	return choices, nil
+}
+
+// usedSubredditsByUse returns the subreddits used before, the most used first
+func (rh *redditHandler) usedSubredditsByUse() []string {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	subreddits := make([]string, 0, len(rh.usedSubreddits))
+	for subreddit := range rh.usedSubreddits {
+		subreddits = append(subreddits, subreddit)
+	}
+
+	sort.Slice(subreddits, func(i, j int) bool {
+		a, b := subreddits[i], subreddits[j]
+		if rh.usedSubreddits[a] != rh.usedSubreddits[b] {
+			return rh.usedSubreddits[a] > rh.usedSubreddits[b]
+		}
+		return a < b
+	})
+	return subreddits
+}
+
+// subredditSuggestions returns the names of the subreddits reddit suggests for the given prefix
+func (c *client) subredditSuggestions(prefix string, nsfw bool) ([]string, error) {
+	values := url.Values{}
+	values.Set("query", prefix)
+	values.Set("limit", strconv.Itoa(redditSuggestionCount))
+	values.Set("include_over_18", strconv.FormatBool(nsfw))
+	values.Set("include_profiles", "false")
+	values.Set("raw_json", "1")
+
+	var r struct {
+		Data struct {
+			Children []struct {
+				Data struct {
+					DisplayName string `json:"display_name"`
+				}
+			}
+		}
+	}
+	err := c.get("/api/subreddit_autocomplete_v2", values, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, child := range r.Data.Children {
+		if child.Data.DisplayName != "" {
+			names = append(names, child.Data.DisplayName)
+		}
+	}
+	return names, nil
+}
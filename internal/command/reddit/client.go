@@ -0,0 +1,412 @@
+package reddit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	anonymousBaseURL = "https://www.reddit.com"
+	oauthBaseURL     = "https://oauth.reddit.com"
+	tokenPath        = "/api/v1/access_token"
+	userAgent        = "linux:lunde:1.0.0 (by /u/haraldfw)"
+	defaultCacheTTL  = time.Minute
+	requestTimeout   = 10 * time.Second
+	maxAttempts      = 3
+	// maxRateLimitWait is the longest a request waits for the rate limit to reset before failing
+	maxRateLimitWait = time.Minute
+	// interactionTimeout is how long requests answering interactions may take, as discord waits
+	// three seconds for the answer
+	interactionTimeout = 2 * time.Second
+)
+
+// clientConfig configures how reddit is accessed. Reddit is accessed anonymously unless the
+// credentials of a script app are given
+type clientConfig struct {
+	ClientID     string `yaml:"clientID"`
+	ClientSecret string `yaml:"clientSecret"`
+	// BaseURL replaces the URL of reddit, also for getting access tokens
+	BaseURL string `yaml:"baseURL"`
+	// CacheTTL is how long responses are reused, defaults to a minute
+	CacheTTL time.Duration `yaml:"cacheTTL"`
+}
+
+// listing is how reddit responds with posts
+type listing struct {
+	Data struct {
+		Children []struct {
+			Data *submission
+		}
+	}
+}
+
+type cachedResponse struct {
+	body    []byte
+	expires time.Time
+}
+
+// client gets listings from reddit, caching the responses and keeping within the rate limit
+type client struct {
+	cfg        clientConfig
+	baseURL    string
+	httpClient *http.Client
+	// timeout is how long getting a response may take in all, or zero for no limit beyond the
+	// timeout of each request
+	timeout time.Duration
+	// attempts is how many times failing requests are tried
+	attempts int
+	// rateLimitWait is the longest requests wait for the rate limit to reset before failing
+	rateLimitWait time.Duration
+
+	*clientState
+}
+
+// clientState is shared by a client and the clients made from it
+type clientState struct {
+	mutex sync.Mutex
+	cache map[string]cachedResponse
+	// remaining is how many requests reddit allows before reset, or negative if unknown
+	remaining float64
+	reset     time.Time
+
+	// tokenMutex is held while getting a token, so only one is requested at a time
+	tokenMutex  sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func newClient(cfg clientConfig) *client {
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = defaultCacheTTL
+	}
+
+	c := &client{
+		cfg:           cfg,
+		baseURL:       anonymousBaseURL,
+		httpClient:    &http.Client{Timeout: requestTimeout},
+		attempts:      maxAttempts,
+		rateLimitWait: maxRateLimitWait,
+		clientState: &clientState{
+			cache:     map[string]cachedResponse{},
+			remaining: -1,
+		},
+	}
+
+	if c.cfg.ClientID != "" {
+		c.baseURL = oauthBaseURL
+	}
+	if c.cfg.BaseURL != "" {
+		c.baseURL = strings.TrimSuffix(c.cfg.BaseURL, "/")
+	}
+
+	return c
+}
+
+// interactive returns a client for answering interactions, which fails instead of retrying or
+// waiting for the rate limit to reset, and gives up after interactionTimeout. It shares the cache,
+// token and rate limit of c
+func (c *client) interactive() *client {
+	ic := *c
+	ic.timeout = interactionTimeout
+	ic.attempts = 1
+	ic.rateLimitWait = 0
+	return &ic
+}
+
+// getPost gets the post at the offset in the listing of the subreddit given by scheme. The random
+// scheme picks a random post among the top posts within the time range instead
+func (c *client) getPost(
+	scheme string, subreddit string, timeRange string, offset int64,
+) (*submission, error) {
+	if scheme == "random" {
+		return c.getRandomPost(subreddit, timeRange)
+	}
+
+	submissions, err := c.getSubredditSubmissions(
+		subreddit, scheme, timeRange, offset+1, offset+1)
+	if err != nil {
+		return nil, fmt.Errorf("getting submissions: %v", err)
+	}
+
+	if len(submissions) < 1 {
+		return nil, fmt.Errorf("reddit returned no posts for subreddit %q", subreddit)
+	}
+
+	if len(submissions)-1 < int(offset) {
+		return nil, fmt.Errorf("reddit did not return enough posts. "+
+			"Reddit returned %d post(s) for subreddit %q, user requested post #%d",
+			len(submissions), subreddit, offset)
+	}
+
+	return submissions[offset], nil
+}
+
+func (c *client) getRandomPost(subreddit string, timeRange string) (*submission, error) {
+	if timeRange == "" {
+		timeRange = "all"
+	}
+
+	submissions, err := c.getSubredditSubmissions(
+		subreddit, "top", timeRange, randomWindowSize, randomWindowSize)
+	if err != nil {
+		return nil, fmt.Errorf("getting submissions: %v", err)
+	}
+
+	if len(submissions) < 1 {
+		return nil, fmt.Errorf("reddit returned no posts for subreddit %q", subreddit)
+	}
+
+	return submissions[rand.Intn(len(submissions))], nil
+}
+
+// getSubredditSubmissions gets the listing of the subreddit given by sort. timeRange is the window
+// of the top and controversial listings, and reddit's default of a day is used if it is empty
+func (c *client) getSubredditSubmissions(
+	subreddit string, sort string, timeRange string, count int64, limit int64,
+) ([]*submission, error) {
+	query := url.Values{}
+	query.Set("count", strconv.FormatInt(count, 10))
+	query.Set("limit", strconv.FormatInt(limit, 10))
+	query.Set("raw_json", "1")
+	if timeRange != "" {
+		query.Set("t", timeRange)
+	}
+
+	var r listing
+	err := c.get(fmt.Sprintf("/r/%s/%s", url.PathEscape(subreddit), sort), query, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	submissions := make([]*submission, len(r.Data.Children))
+	for i, child := range r.Data.Children {
+		submissions[i] = child.Data
+	}
+
+	return submissions, nil
+}
+
+// get decodes the JSON reddit responds with at the path, reusing responses younger than the cache
+// TTL
+func (c *client) get(path string, query url.Values, v interface{}) error {
+	requestURL := c.baseURL + path + ".json?" + query.Encode()
+
+	c.mutex.Lock()
+	cached, exists := c.cache[requestURL]
+	c.mutex.Unlock()
+
+	body := cached.body
+	if !exists || time.Now().After(cached.expires) {
+		var err error
+		body, err = c.fetch(requestURL)
+		if err != nil {
+			return err
+		}
+		c.store(requestURL, body)
+	}
+
+	err := json.Unmarshal(body, v)
+	if err != nil {
+		return fmt.Errorf("decoding response body of %s: %v", path, err)
+	}
+	return nil
+}
+
+// fetch gets the URL, waiting for the rate limit to reset if it is used up and retrying when
+// reddit is rate limiting or failing
+func (c *client) fetch(requestURL string) (body []byte, err error) {
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	for attempt := 1; attempt <= c.attempts; attempt++ {
+		err = c.waitForRateLimit()
+		if err != nil {
+			return
+		}
+
+		var retry bool
+		body, retry, err = c.do(ctx, requestURL)
+		if err == nil || !retry || attempt == c.attempts {
+			return
+		}
+
+		logrus.Warnf("reddit request attempt %d failed: %v", attempt, err)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+
+	return
+}
+
+func (c *client) do(ctx context.Context, requestURL string) (
+	body []byte, retry bool, err error,
+) {
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		err = fmt.Errorf("creating request: %v", err)
+		return
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	if c.cfg.ClientID != "" {
+		var token string
+		token, err = c.accessToken(ctx)
+		if err != nil {
+			return
+		}
+		req.Header.Set("Authorization", "bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		err = fmt.Errorf("doing request: %v", err)
+		retry = true
+		return
+	}
+
+	defer resp.Body.Close()
+	c.updateRateLimit(resp.Header)
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = fmt.Errorf("reading response body: %v", err)
+		retry = true
+		return
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized && c.cfg.ClientID != "":
+		// the token was revoked or expired early, so a new one is needed
+		c.tokenMutex.Lock()
+		c.token = ""
+		c.tokenMutex.Unlock()
+		retry = true
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		retry = true
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return
+	}
+
+	err = fmt.Errorf("reddit returned unexpected non-200 response code %q", resp.Status)
+	return
+}
+
+// accessToken returns a token for the app, getting a new one if it has none or it has expired
+func (c *client) accessToken(ctx context.Context) (string, error) {
+	c.tokenMutex.Lock()
+	defer c.tokenMutex.Unlock()
+
+	if c.token != "" && time.Now().Before(c.tokenExpiry) {
+		return c.token, nil
+	}
+
+	tokenURL := anonymousBaseURL + tokenPath
+	if c.cfg.BaseURL != "" {
+		tokenURL = c.baseURL + tokenPath
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL,
+		strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("creating token request: %v", err)
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(c.cfg.ClientID, c.cfg.ClientSecret)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("doing token request: %v", err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("reddit returned unexpected response code %q for token request",
+			resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", fmt.Errorf("decoding token response: %v", err)
+	}
+
+	c.token = token.AccessToken
+	// renew the token a minute early so it does not expire during requests
+	c.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
+
+// updateRateLimit remembers how many requests are left before the rate limit resets, as given by
+// the X-Ratelimit headers
+func (c *client) updateRateLimit(header http.Header) {
+	remaining, err := strconv.ParseFloat(header.Get("X-Ratelimit-Remaining"), 64)
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseFloat(header.Get("X-Ratelimit-Reset"), 64)
+	if err != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.remaining = remaining
+	c.reset = time.Now().Add(time.Duration(reset * float64(time.Second)))
+}
+
+// waitForRateLimit waits until the rate limit resets if there are no requests left, failing if it
+// resets later than the client waits
+func (c *client) waitForRateLimit() error {
+	c.mutex.Lock()
+	wait := time.Duration(0)
+	if c.remaining >= 0 && c.remaining < 1 {
+		wait = time.Until(c.reset)
+	}
+	c.mutex.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	if wait > c.rateLimitWait {
+		return fmt.Errorf("reddit rate limit used up, resets in %s", wait.Round(time.Second))
+	}
+
+	time.Sleep(wait)
+	return nil
+}
+
+// store caches the response body, dropping the expired responses
+func (c *client) store(requestURL string, body []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for key, cached := range c.cache {
+		if now.After(cached.expires) {
+			delete(c.cache, key)
+		}
+	}
+
+	c.cache[requestURL] = cachedResponse{body: body, expires: now.Add(c.cfg.CacheTTL)}
+}
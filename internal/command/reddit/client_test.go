@@ -0,0 +1,216 @@
+package reddit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer serves reddit responses with the handler, counting the requests to each path
+type testServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests map[string]int
+}
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) *testServer {
+	ts := &testServer{requests: map[string]int{}}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mutex.Lock()
+		ts.requests[r.URL.Path]++
+		ts.mutex.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *testServer) count(path string) int {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	return ts.requests[path]
+}
+
+func listingHandler(w http.ResponseWriter, _ *http.Request) {
+	fmt.Fprint(w, `{"data": {"children": [{"data": {"id": "abc", "title": "a post"}}]}}`)
+}
+
+func TestGetCachesResponses(t *testing.T) {
+	ts := newTestServer(t, listingHandler)
+	c := newClient(clientConfig{BaseURL: ts.URL})
+
+	for i := 0; i < 2; i++ {
+		submissions, err := c.getSubredditSubmissions("golang", "hot", "", 1, 1)
+		if err != nil {
+			t.Fatalf("getting submissions: %v", err)
+		}
+		if len(submissions) != 1 || submissions[0].ID != "abc" {
+			t.Fatalf("got submissions %+v, want the post abc", submissions)
+		}
+	}
+	if n := ts.count("/r/golang/hot.json"); n != 1 {
+		t.Errorf("got %d requests for the same listing, want 1 as the response is cached", n)
+	}
+
+	_, err := c.getSubredditSubmissions("golang", "hot", "", 2, 2)
+	if err != nil {
+		t.Fatalf("getting submissions: %v", err)
+	}
+	if n := ts.count("/r/golang/hot.json"); n != 2 {
+		t.Errorf("got %d requests, want 2 as a different query is not cached", n)
+	}
+
+	c.cfg.CacheTTL = -time.Second
+	c.store("expired", nil)
+	c.mutex.Lock()
+	_, exists := c.cache["expired"]
+	c.mutex.Unlock()
+	if !exists {
+		t.Fatal("response not stored")
+	}
+	c.store("other", nil)
+	c.mutex.Lock()
+	_, exists = c.cache["expired"]
+	c.mutex.Unlock()
+	if exists {
+		t.Error("expired response not dropped when storing another")
+	}
+}
+
+func TestFetchRetriesTooManyRequests(t *testing.T) {
+	var mutex sync.Mutex
+	limited := map[string]bool{}
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !limited[r.URL.Path] {
+			limited[r.URL.Path] = true
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		listingHandler(w, r)
+	})
+	c := newClient(clientConfig{BaseURL: ts.URL})
+
+	_, err := c.getSubredditSubmissions("golang", "hot", "", 1, 1)
+	if err != nil {
+		t.Fatalf("getting submissions: %v", err)
+	}
+	if n := ts.count("/r/golang/hot.json"); n != 2 {
+		t.Errorf("got %d requests, want 2 as the rate limited request is retried", n)
+	}
+
+	_, err = c.interactive().getSubredditSubmissions("golang", "new", "", 1, 1)
+	if err == nil {
+		t.Error("got no error from the interactive client, want it to fail without retrying")
+	}
+	if n := ts.count("/r/golang/new.json"); n != 1 {
+		t.Errorf("got %d requests from the interactive client, want 1", n)
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Ratelimit-Remaining", "0")
+		w.Header().Set("X-Ratelimit-Reset", "120")
+		listingHandler(w, r)
+	})
+	c := newClient(clientConfig{BaseURL: ts.URL})
+
+	_, err := c.getSubredditSubmissions("golang", "hot", "", 1, 1)
+	if err != nil {
+		t.Fatalf("getting submissions: %v", err)
+	}
+
+	// the limit resets later than both clients wait, so neither makes the request
+	_, err = c.getSubredditSubmissions("golang", "top", "", 1, 1)
+	if err == nil {
+		t.Error("got no error, want the used up rate limit to fail the request")
+	}
+	_, err = c.interactive().getSubredditSubmissions("golang", "top", "", 1, 1)
+	if err == nil {
+		t.Error("got no error from the interactive client, want the used up rate limit to fail it")
+	}
+	if n := ts.count("/r/golang/top.json"); n != 0 {
+		t.Errorf("got %d requests while the rate limit was used up, want 0", n)
+	}
+
+	c.updateRateLimit(http.Header{
+		"X-Ratelimit-Remaining": {"0"},
+		"X-Ratelimit-Reset":     {"0.2"},
+	})
+
+	start := time.Now()
+	_, err = c.getSubredditSubmissions("golang", "top", "", 1, 1)
+	if err != nil {
+		t.Fatalf("getting submissions: %v", err)
+	}
+	if waited := time.Since(start); waited < 150*time.Millisecond {
+		t.Errorf("request made after %s, want it to wait for the rate limit to reset", waited)
+	}
+}
+
+func TestTokenRefreshedOnUnauthorized(t *testing.T) {
+	var mutex sync.Mutex
+	issued := 0
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if r.URL.Path == tokenPath {
+			id, secret, ok := r.BasicAuth()
+			if !ok || id != "id" || secret != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			issued++
+			fmt.Fprintf(w, `{"access_token": "token%d", "expires_in": 3600}`, issued)
+			return
+		}
+
+		// the first token is revoked
+		if r.Header.Get("Authorization") != "bearer token2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		listingHandler(w, r)
+	})
+	c := newClient(clientConfig{ClientID: "id", ClientSecret: "secret", BaseURL: ts.URL})
+
+	_, err := c.getSubredditSubmissions("golang", "hot", "", 1, 1)
+	if err != nil {
+		t.Fatalf("getting submissions: %v", err)
+	}
+	if n := ts.count(tokenPath); n != 2 {
+		t.Errorf("got %d token requests, want 2 as the revoked token is replaced", n)
+	}
+
+	_, err = c.getSubredditSubmissions("golang", "new", "", 1, 1)
+	if err != nil {
+		t.Fatalf("getting submissions: %v", err)
+	}
+	if n := ts.count(tokenPath); n != 2 {
+		t.Errorf("got %d token requests, want 2 as the valid token is reused", n)
+	}
+}
+
+func TestGetDecodeErrorOmitsBody(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "<html>secret page</html>")
+	})
+	c := newClient(clientConfig{BaseURL: ts.URL})
+
+	var r listing
+	err := c.get("/r/golang/hot", url.Values{}, &r)
+	if err == nil {
+		t.Fatal("got no error decoding HTML")
+	}
+	if strings.Contains(err.Error(), "secret page") {
+		t.Errorf("got error %q, want it without the response body", err)
+	}
+}
@@ -0,0 +1,113 @@
+package reddit
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/jzelinskie/geddit"
+)
+
+// maxGalleryImages is how many images of a gallery are shown, as discord groups at most four
+// embeds with the same URL into one gallery
+const maxGalleryImages = 4
+
+// submission is a reddit post with the media fields geddit does not decode
+type submission struct {
+	geddit.Submission
+	Spoiler       bool                     `json:"spoiler"`
+	IsGallery     bool                     `json:"is_gallery"`
+	MediaMetadata map[string]mediaMetadata `json:"media_metadata"`
+	GalleryData   *struct {
+		Items []struct {
+			MediaID string `json:"media_id"`
+		} `json:"items"`
+	} `json:"gallery_data"`
+	SecureMedia *struct {
+		RedditVideo *redditVideo `json:"reddit_video"`
+	} `json:"secure_media"`
+	Preview *struct {
+		Images []struct {
+			Source struct {
+				URL string `json:"url"`
+			} `json:"source"`
+		} `json:"images"`
+		RedditVideoPreview *redditVideo `json:"reddit_video_preview"`
+	} `json:"preview"`
+	PostHint string `json:"post_hint"`
+	// CrosspostParentList holds the post crossposted, whose media is shown instead
+	CrosspostParentList []*submission `json:"crosspost_parent_list"`
+}
+
+type mediaMetadata struct {
+	Status string `json:"status"`
+	Source struct {
+		URL string `json:"u"`
+		GIF string `json:"gif"`
+	} `json:"s"`
+}
+
+type redditVideo struct {
+	FallbackURL string `json:"fallback_url"`
+}
+
+// media is what a post shows. Posts have either a video or images, or neither if they only link
+// to something else
+type media struct {
+	Video string
+	// Images holds the images of the post, of which there are several if it is a gallery
+	Images []string
+	// ImageCount is how many images the post has, including the ones not shown
+	ImageCount int
+}
+
+// resolveMedia picks the URLs discord can show for the media of the post, from the post
+// crossposted if it is a crosspost
+func resolveMedia(post *submission) (m media) {
+	if len(post.CrosspostParentList) > 0 && post.CrosspostParentList[0] != nil {
+		post = post.CrosspostParentList[0]
+	}
+
+	switch {
+	case post.IsGallery && post.GalleryData != nil:
+		for _, item := range post.GalleryData.Items {
+			meta, exists := post.MediaMetadata[item.MediaID]
+			if !exists || meta.Status != "valid" {
+				continue
+			}
+
+			m.ImageCount++
+			if len(m.Images) >= maxGalleryImages {
+				continue
+			}
+
+			if meta.Source.GIF != "" {
+				m.Images = append(m.Images, meta.Source.GIF)
+			} else if meta.Source.URL != "" {
+				m.Images = append(m.Images, meta.Source.URL)
+			}
+		}
+	case post.SecureMedia != nil && post.SecureMedia.RedditVideo != nil:
+		m.Video = post.SecureMedia.RedditVideo.FallbackURL
+	case post.Preview != nil && post.Preview.RedditVideoPreview != nil:
+		// gifs hosted elsewhere are converted to videos by reddit
+		m.Video = post.Preview.RedditVideoPreview.FallbackURL
+	case isGifv(post.URL):
+		m.Video = strings.TrimSuffix(post.URL, ".gifv") + ".mp4"
+	case isEmbeddable(post.URL):
+		m.Images = []string{post.URL}
+		m.ImageCount = 1
+	case post.PostHint == "image" && post.Preview != nil && len(post.Preview.Images) > 0:
+		m.Images = []string{post.Preview.Images[0].Source.URL}
+		m.ImageCount = 1
+	}
+
+	return
+}
+
+func isGifv(postURL string) bool {
+	u, err := url.Parse(postURL)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(u.Host, "imgur.com") && strings.HasSuffix(u.Path, ".gifv")
+}
@@ -0,0 +1,112 @@
+package reddit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// what is done with NSFW posts requested in channels that are not NSFW
+const (
+	policyRefuse  = "refuse"
+	policySpoiler = "spoiler"
+	policyDM      = "dm"
+)
+
+// contentConfig decides what reddit posts are posted
+type contentConfig struct {
+	// NSFWPolicy is one of refuse, spoiler and dm, and defaults to refuse
+	NSFWPolicy string `yaml:"nsfwPolicy"`
+	// AllowedSubreddits are the only subreddits posted from if any are given
+	AllowedSubreddits []string `yaml:"allowedSubreddits"`
+	DeniedSubreddits  []string `yaml:"deniedSubreddits"`
+}
+
+func (cc *contentConfig) validate() error {
+	switch cc.NSFWPolicy {
+	case "":
+		cc.NSFWPolicy = policyRefuse
+	case policyRefuse, policySpoiler, policyDM:
+	default:
+		return fmt.Errorf("unknown nsfwPolicy %q, must be one of %s, %s and %s",
+			cc.NSFWPolicy, policyRefuse, policySpoiler, policyDM)
+	}
+	return nil
+}
+
+// checkSubreddit returns an error if posts from the subreddit are not allowed
+func (cc *contentConfig) checkSubreddit(subreddit string) error {
+	subreddit = strings.TrimPrefix(strings.ToLower(subreddit), "r/")
+
+	for _, denied := range cc.DeniedSubreddits {
+		if strings.EqualFold(denied, subreddit) {
+			return fmt.Errorf("posts from r/%s are not allowed", subreddit)
+		}
+	}
+
+	if len(cc.AllowedSubreddits) == 0 {
+		return nil
+	}
+	for _, allowed := range cc.AllowedSubreddits {
+		if strings.EqualFold(allowed, subreddit) {
+			return nil
+		}
+	}
+	return fmt.Errorf("posts from r/%s are not allowed", subreddit)
+}
+
+// channelMessage returns the post as it is posted in the channel when nobody requested it, or
+// false if it is not posted at all. NSFW posts are only posted in channels that are not NSFW as
+// spoilers, and only if that is the policy
+func (rh *redditHandler) channelMessage(post *submission, ch *discord.Channel) (
+	content string, embeds []discord.Embed, ok bool,
+) {
+	if rh.content.checkSubreddit(post.Subreddit) != nil {
+		return
+	}
+
+	if post.IsNSFW && !ch.NSFW {
+		if rh.content.NSFWPolicy != policySpoiler {
+			return
+		}
+		return spoilerLink(post), nil, true
+	}
+
+	content, embeds = postMessage(post)
+	return content, embeds, true
+}
+
+// nsfwResponse returns what to respond to the user requesting an NSFW post in a channel that is
+// not NSFW, sending the post to the user in a DM if that is the policy
+func (rh *redditHandler) nsfwResponse(user discord.User, nick string, post *submission) (
+	string, error,
+) {
+	switch rh.content.NSFWPolicy {
+	case policySpoiler:
+		return spoilerLink(post), nil
+	case policyDM:
+		dm, err := rh.srv.Session.CreatePrivateChannel(user.ID)
+		if err != nil {
+			return "", fmt.Errorf("creating private channel: %v", err)
+		}
+
+		content, embeds := postMessage(post)
+		_, err = rh.srv.Session.SendMessageComplex(dm.ID, api.SendMessageData{
+			Content: content,
+			Embeds:  embeds,
+		})
+		if err != nil {
+			return "", fmt.Errorf("sending NSFW post in DM: %v", err)
+		}
+		return fmt.Sprintf("the post is NSFW, so it was sent to %s in a DM", nick), nil
+	}
+
+	return fmt.Sprintf("this is a christian channel, %s", nick), nil
+}
+
+func spoilerLink(post *submission) string {
+	return fmt.Sprintf("⚠️ NSFW post from r/%s, open at your own risk: ||https://reddit.com%s||",
+		post.Subreddit, post.Permalink)
+}
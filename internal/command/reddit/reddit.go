@@ -1,72 +1,213 @@
 package reddit
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
 	"github.com/diamondburned/arikawa/v3/gateway"
-	"github.com/diamondburned/arikawa/v3/session"
 	"github.com/diamondburned/arikawa/v3/utils/json/option"
-	"github.com/jzelinskie/geddit"
+	"github.com/haraldfw/cfger"
 	"github.com/polarbirds/lunde/internal/command"
 	"github.com/polarbirds/lunde/internal/server"
 )
 
+const (
+	// maxOffset is the highest offset of posts that can be browsed to
+	maxOffset = 200
+	// randomWindowSize is how many of the top posts random posts are picked among
+	randomWindowSize = 100
+)
+
+var timeRanges = []discord.StringChoice{
+	{Name: "hour", Value: "hour"},
+	{Name: "day", Value: "day"},
+	{Name: "week", Value: "week"},
+	{Name: "month", Value: "month"},
+	{Name: "year", Value: "year"},
+	{Name: "all", Value: "all"},
+}
+
+type redditConfig struct {
+	Reddit struct {
+		clientConfig  `yaml:",inline"`
+		contentConfig `yaml:",inline"`
+	} `yaml:"reddit"`
+}
+
 type redditHandler struct {
-	session *session.Session
+	srv *server.Server
+	// client retries and waits for the rate limit, for polling subscriptions and unfurling links
+	client *client
+	// interactionClient fails fast, for answering interactions within the time discord waits
+	interactionClient *client
+	content           contentConfig
+	subscriptions     subscriptions
+	unfurl            unfurlSettings
+	// usedSubreddits counts the uses of the subreddits used before
+	usedSubreddits map[string]int
+	mutex          sync.Mutex
 }
 
 func intToPtr(i int) *int {
 	return &i
 }
 
-// CreateCommand creates a LundeCommand which handles /reddit
-func CreateCommand(srv *server.Server) (cmd command.LundeCommand, err error) {
-	rh := redditHandler{srv.Session}
-	cmd = command.LundeCommand{
-		HandleInteraction: rh.handleInteraction,
-		CommandData: api.CreateCommandData{
-			Name: "reddit",
-			Description: "fetches reddit posts the given subreddit sorted by the given " +
-				"parameters",
-			Options: []discord.CommandOption{
-				&discord.StringOption{
-					OptionName:  "sort",
-					Description: "what algorithm to sort posts by",
-					Required:    true,
-					Choices: []discord.StringChoice{
-						{Name: "top", Value: "top"},
-						{Name: "hot", Value: "hot"},
-						{Name: "controversial", Value: "controversial"},
-						{Name: "random", Value: "random"},
+// CreateCommands returns the creators of the reddit command, which fetches reddit posts, and the
+// subreddit command, which searches reddit and manages subreddit subscriptions. The commands share
+// a handler, which starts polling the subreddits subscribed to
+func CreateCommands() (createReddit server.CreateCommand, createSubreddit server.CreateCommand) {
+	var rh *redditHandler
+	handler := func(srv *server.Server) (*redditHandler, error) {
+		if rh != nil {
+			return rh, nil
+		}
+
+		created, err := newRedditHandler(srv)
+		if err != nil {
+			return nil, err
+		}
+		rh = created
+		return rh, nil
+	}
+
+	createReddit = func(srv *server.Server) (cmd command.LundeCommand, err error) {
+		rh, err := handler(srv)
+		if err != nil {
+			return
+		}
+
+		cmd = command.LundeCommand{
+			HandleInteraction:  rh.handlePost,
+			HandleComponent:    rh.handleComponent,
+			HandleAutocomplete: rh.handleAutocomplete,
+			CommandData: api.CreateCommandData{
+				Name: "reddit",
+				Description: "fetches reddit posts the given subreddit sorted by the given " +
+					"parameters",
+				Options: []discord.CommandOption{
+					&discord.StringOption{
+						OptionName:  "sort",
+						Description: "what algorithm to sort posts by",
+						Required:    true,
+						Choices: []discord.StringChoice{
+							{Name: "top", Value: "top"},
+							{Name: "hot", Value: "hot"},
+							{Name: "controversial", Value: "controversial"},
+							{Name: "random", Value: "random"},
+						},
+					},
+					&discord.StringOption{
+						OptionName:   "sub",
+						Description:  "what subreddit to fetch from",
+						Required:     true,
+						Autocomplete: true,
+					},
+					&discord.IntegerOption{
+						OptionName:  "offset",
+						Description: "how many posts to skip",
+						Required:    false,
+						Min:         option.Int(intToPtr(0)),
+						Max:         option.Int(intToPtr(maxOffset)),
+					},
+					&discord.StringOption{
+						OptionName: "time",
+						Description: "time range of top and controversial posts, and of the " +
+							"top posts random picks among",
+						Choices: timeRanges,
 					},
 				},
-				&discord.StringOption{
-					OptionName:  "sub",
-					Description: "what subreddit to fetch from",
-					Required:    true,
-				},
-				&discord.IntegerOption{
-					OptionName:  "offset",
-					Description: "how many posts to skip",
-					Required:    false,
-					Min:         option.Int(intToPtr(0)),
-					Max:         option.Int(intToPtr(200)),
+			},
+		}
+		return
+	}
+
+	createSubreddit = func(srv *server.Server) (cmd command.LundeCommand, err error) {
+		rh, err := handler(srv)
+		if err != nil {
+			return
+		}
+
+		cmd = command.LundeCommand{
+			HandleInteraction:  rh.handleSubreddit,
+			HandleAutocomplete: rh.handleAutocomplete,
+			CommandData: api.CreateCommandData{
+				Name:        "subreddit",
+				Description: "searches reddit posts and manages subreddit subscriptions",
+				Options: []discord.CommandOption{
+					searchCommandOption(),
+					subscribeCommandOption(),
+					&discord.SubcommandOption{
+						OptionName:  "unsubscribe",
+						Description: "stop posting new posts from a subreddit",
+						Options: []discord.CommandOptionValue{
+							&discord.IntegerOption{
+								OptionName: "id",
+								Description: "ID of the subscription, as shown by " +
+									"/subreddit subscriptions",
+								Required: true,
+							},
+						},
+					},
+					&discord.SubcommandOption{
+						OptionName:  "subscriptions",
+						Description: "list the subreddit subscriptions",
+					},
+					unfurlCommandOption(),
 				},
 			},
-		},
+		}
+		return
 	}
 
 	return
 }
 
+func newRedditHandler(srv *server.Server) (rh *redditHandler, err error) {
+	var cfg redditConfig
+	_, err = cfger.ReadStructuredCfgRecursive("env::CONFIG", &cfg)
+	if err != nil {
+		err = fmt.Errorf("reading reddit config: %v", err)
+		return
+	}
+
+	err = cfg.Reddit.contentConfig.validate()
+	if err != nil {
+		err = fmt.Errorf("reddit config: %v", err)
+		return
+	}
+
+	rh = &redditHandler{
+		srv:           srv,
+		client:        newClient(cfg.Reddit.clientConfig),
+		content:       cfg.Reddit.contentConfig,
+		subscriptions: subscriptions{NextID: 1},
+	}
+	rh.interactionClient = rh.client.interactive()
+
+	err = rh.loadSubscriptions()
+	if err != nil {
+		return
+	}
+
+	err = rh.loadUnfurlSettings()
+	if err != nil {
+		return
+	}
+
+	err = rh.loadUsedSubreddits()
+	if err != nil {
+		return
+	}
+
+	rh.srv.AddMessageCreateHandler(rh.handleMessageCreate)
+	return
+}
+
 func utcToTimeStamp(utc int64) string {
 	tm := time.Unix(utc, 0)
 	return tm.Format("02.01.06")
@@ -82,7 +223,8 @@ func isEmbeddable(url string) bool {
 	return false
 }
 
-func embedMessage(resp *geddit.Submission) discord.Embed {
+// embedMessage embeds the post, showing the given image if it is not empty
+func embedMessage(resp *submission, image string) discord.Embed {
 	embed := discord.Embed{
 		Title:       resp.Title,
 		Description: resp.Selftext,
@@ -98,30 +240,69 @@ func embedMessage(resp *geddit.Submission) discord.Embed {
 		},
 	}
 
-	if len(embed.Description) > 1999 {
-		embed.Description = embed.Description[:1999]
+	embed.Description = command.Truncate(embed.Description, 1995)
+
+	if resp.Spoiler {
+		embed.Title = "[spoiler] " + embed.Title
+		embed.Description = spoiler(embed.Description)
 	}
 
-	if !strings.HasSuffix(resp.URL, resp.Permalink) {
-		embed.Image = &discord.EmbedImage{URL: resp.URL}
+	if image != "" {
+		embed.Image = &discord.EmbedImage{URL: image}
 	}
 	return embed
 }
 
-func (rh *redditHandler) handleInteraction(
+func (rh *redditHandler) handleSubreddit(
 	event *gateway.InteractionCreateEvent,
 	options map[string]discord.CommandInteractionOption,
 ) (
 	response *api.InteractionResponseData, err error,
 ) {
-	var recChan *discord.Channel
-	recChan, err = rh.session.Channel(event.ChannelID)
+	subcommand, subOptions, err := command.Subcommand(options)
+	if err != nil {
+		return
+	}
+
+	if subcommand == "subscribe" || subcommand == "unsubscribe" || subcommand == "unfurl" {
+		err = command.RequirePermission(rh.srv.Session, event, discord.PermissionManageChannels)
+		if err != nil {
+			return
+		}
+	}
+
+	var msg string
+	switch subcommand {
+	case "search":
+		return rh.search(event, subOptions)
+	case "subscribe":
+		msg, err = rh.subscribe(event, subOptions)
+	case "unsubscribe":
+		msg, err = rh.unsubscribe(subOptions)
+	case "subscriptions":
+		msg = rh.listSubscriptions()
+	case "unfurl":
+		msg, err = rh.setUnfurl(event, subOptions)
+	default:
+		err = fmt.Errorf("unknown subcommand %q", subcommand)
+	}
 	if err != nil {
-		err = fmt.Errorf("get channel when handling /reddit: %v", err)
 		return
 	}
 
-	subreddit := options["sub"].String()
+	response = &api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+	}
+	return
+}
+
+func (rh *redditHandler) handlePost(
+	event *gateway.InteractionCreateEvent,
+	options map[string]discord.CommandInteractionOption,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	subreddit := strings.TrimPrefix(strings.TrimSpace(options["sub"].String()), "r/")
 
 	offset, err := options["offset"].IntValue()
 	if err != nil {
@@ -134,126 +315,191 @@ func (rh *redditHandler) handleInteraction(
 		offset = 0
 	}
 
-	resp, err := getPost(options["sort"].String(), subreddit, offset)
+	response, err = rh.postResponse(
+		event, options["sort"].String(), options["time"].String(), subreddit, offset)
+	if err == nil {
+		rh.recordSubreddit(subreddit)
+	}
+	return
+}
+
+// handleComponent handles the buttons for browsing posts, replacing the post with the one the
+// button leads to
+func (rh *redditHandler) handleComponent(event *gateway.InteractionCreateEvent, customID string) (
+	response *api.InteractionResponseData, err error,
+) {
+	parts := strings.Split(customID, ":")
+	if len(parts) != 5 || parts[0] != "post" {
+		err = fmt.Errorf("unknown reddit component %q", customID)
+		return
+	}
+
+	offset, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		err = fmt.Errorf("parsing offset of component %q: %v", customID, err)
+		return
+	}
+
+	return rh.postResponse(event, parts[1], parts[2], parts[3], offset)
+}
+
+// postResponse responds with the post at the offset in the subreddit, with buttons to browse to
+// the previous, next or a random post
+func (rh *redditHandler) postResponse(
+	event *gateway.InteractionCreateEvent, sort string, timeRange string, subreddit string,
+	offset int64,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	recChan, err := rh.srv.Session.Channel(event.ChannelID)
+	if err != nil {
+		err = fmt.Errorf("get channel when handling /reddit: %v", err)
+		return
+	}
+
+	err = rh.content.checkSubreddit(subreddit)
+	if err != nil {
+		return
+	}
+
+	resp, err := rh.interactionClient.getPost(sort, subreddit, timeRange, offset)
 	if err != nil {
 		err = fmt.Errorf("getting post: %v", err)
 		return
 	}
 
+	// listings like r/all have posts from other subreddits
+	err = rh.content.checkSubreddit(resp.Subreddit)
+	if err != nil {
+		return
+	}
+
 	if resp.IsNSFW && !recChan.NSFW {
 		nick := event.Member.Nick
 		if nick == "" {
 			nick = event.Member.User.Username
 		}
+
+		var content string
+		content, err = rh.nsfwResponse(event.Member.User, nick, resp)
+		if err != nil {
+			return
+		}
+
 		response = &api.InteractionResponseData{
-			Content: option.NewNullableString(
-				fmt.Sprintf("this is a christian channel, %s", nick)),
+			Content:    option.NewNullableString(content),
+			Embeds:     &[]discord.Embed{},
+			Components: browseButtons(sort, timeRange, subreddit, offset),
 		}
 		return
 	}
 
-	response = &api.InteractionResponseData{}
-	if strings.HasSuffix(resp.URL, resp.Permalink) || isEmbeddable(resp.URL) {
-		response.Embeds = &[]discord.Embed{embedMessage(resp)}
-	} else {
-		title := fmt.Sprintf("*%s on %s*: <https://reddit.com%s>\n%s",
-			resp.Author, utcToTimeStamp(int64(resp.DateCreated)), resp.Permalink, resp.Title)
-		var messageBody string
-		if resp.Selftext != "" {
-			messageBody = fmt.Sprintf("%s\n%s", resp.Selftext, resp.URL)
-		} else {
-			messageBody = resp.URL
-		}
-		response.Content = option.NewNullableString(
-			fmt.Sprintf("%s\n%s\n⬆%v", title, messageBody, resp.Ups))
+	// both content and embeds are always set, so a post replacing another clears it entirely
+	content, embeds := postMessage(resp)
+	response = &api.InteractionResponseData{
+		Content:    option.NewNullableString(content),
+		Embeds:     &embeds,
+		Components: browseButtons(sort, timeRange, subreddit, offset),
 	}
 
 	return
 }
 
-func getPost(scheme string, subreddit string, offset int64) (*geddit.Submission, error) {
-	submissions, err := getSubredditSubmissions(subreddit, scheme, offset+1, offset+1)
-	if err != nil {
-		return nil, fmt.Errorf("getting submissions: %v", err)
-	}
-
-	if len(submissions) < 1 {
-		return nil, fmt.Errorf("reddit returned no posts for subreddit %q", subreddit)
+func browseButtons(
+	sort string, timeRange string, subreddit string, offset int64,
+) *discord.ContainerComponents {
+	postID := func(sort string, offset int64) discord.ComponentID {
+		return command.ComponentID("reddit",
+			fmt.Sprintf("post:%s:%s:%s:%d", sort, timeRange, subreddit, offset))
 	}
 
-	if len(submissions)-1 < int(offset) {
-		return nil, fmt.Errorf("reddit did not return enough posts. "+
-			"Reddit returned %d post(s) for subreddit %q, user requested post #%d",
-			len(submissions), subreddit, offset)
+	previousOffset := offset - 1
+	if previousOffset < 0 {
+		previousOffset = 0
 	}
 
-	return submissions[offset], nil
+	return discord.ComponentsPtr(&discord.ActionRowComponent{
+		&discord.ButtonComponent{
+			Style:    discord.SecondaryButtonStyle(),
+			Label:    "Previous",
+			CustomID: postID(sort, previousOffset),
+			Disabled: offset == 0 || sort == "random",
+		},
+		&discord.ButtonComponent{
+			Style:    discord.SecondaryButtonStyle(),
+			Label:    "Next",
+			CustomID: postID(sort, offset+1),
+			Disabled: sort == "random" || offset >= maxOffset,
+		},
+		&discord.ButtonComponent{
+			Style:    discord.PrimaryButtonStyle(),
+			Label:    "Random",
+			CustomID: postID("random", 0),
+		},
+	})
 }
 
-func getSubredditSubmissions(
-	subreddit string, sort string, count int64, limit int64,
-) ([]*geddit.Submission, error) {
-	redditURL := fmt.Sprintf(
-		"https://www.reddit.com/r/%s/%s.json?count=%d&limit=%d",
-		subreddit, sort, count, limit)
-
-	req, err := http.NewRequest("GET", redditURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %v", err)
-	}
-
-	req.Header.Set("User-Agent", "linux:lunde:1.0.0 (by /u/haraldfw) ")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("doing request: %v", err)
-	}
-
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response body: %v", err)
-	}
+// postMessage returns the post as embeds if its media can be embedded or it is a text post,
+// otherwise as message content. Videos are linked in the content for discord to play them, and the
+// media of spoilers is hidden behind spoiler tags instead of embedded
+func postMessage(resp *submission) (content string, embeds []discord.Embed) {
+	m := resolveMedia(resp)
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return nil, fmt.Errorf("reddit returned unexpected non-200 response code %q", resp.Status)
-	}
+	if m.Video != "" || (resp.Spoiler && len(m.Images) > 0) {
+		urls := m.Images
+		if m.Video != "" {
+			urls = []string{m.Video}
+		}
 
-	type Response struct {
-		Data struct {
-			Children []struct {
-				Data *geddit.Submission
+		lines := []string{}
+		for _, u := range urls {
+			if resp.Spoiler {
+				u = spoiler(u)
 			}
+			lines = append(lines, u)
 		}
-	}
 
-	var r Response
+		return strings.Join(lines, "\n"), []discord.Embed{embedMessage(resp, "")}
+	}
 
-	if sort == "random" {
-		var postsRes []Response
-		err = json.Unmarshal(bodyBytes, &postsRes)
-		if err != nil {
-			return nil, fmt.Errorf("decoding response %q: %v", string(bodyBytes), err)
+	if len(m.Images) > 0 {
+		embeds = []discord.Embed{embedMessage(resp, m.Images[0])}
+		if m.ImageCount > 1 {
+			embeds[0].Footer.Text += fmt.Sprintf(" · %d images", m.ImageCount)
 		}
 
-		if len(postsRes) < 1 {
-			return nil, errors.New("no posts returned for subreddit")
-		}
-		r = postsRes[0]
-	} else {
-		var postRes Response
-		err = json.Unmarshal(bodyBytes, &postRes)
-		if err != nil {
-			return nil, fmt.Errorf("decoding response body %q: %v", string(bodyBytes), err)
+		// embeds with the same URL are shown as one gallery
+		for _, image := range m.Images[1:] {
+			embeds = append(embeds, discord.Embed{
+				URL:   embeds[0].URL,
+				Image: &discord.EmbedImage{URL: image},
+			})
 		}
+		return
+	}
 
-		r = postRes
+	if strings.HasSuffix(resp.URL, resp.Permalink) {
+		return "", []discord.Embed{embedMessage(resp, "")}
 	}
 
-	submissions := make([]*geddit.Submission, len(r.Data.Children))
-	for i, child := range r.Data.Children {
-		submissions[i] = child.Data
+	title := fmt.Sprintf("*%s on %s*: <https://reddit.com%s>\n%s",
+		resp.Author, utcToTimeStamp(int64(resp.DateCreated)), resp.Permalink, resp.Title)
+	var messageBody string
+	if resp.Selftext != "" {
+		messageBody = fmt.Sprintf("%s\n%s", resp.Selftext, resp.URL)
+	} else {
+		messageBody = resp.URL
 	}
+	if resp.Spoiler {
+		messageBody = spoiler(messageBody)
+	}
+
+	return fmt.Sprintf("%s\n%s\n⬆%v", title, messageBody, resp.Ups), nil
+}
 
-	return submissions, nil
+func spoiler(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "||" + text + "||"
 }
@@ -0,0 +1,160 @@
+package reddit
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/polarbirds/lunde/internal/command"
+)
+
+// searchResultCount is how many posts found by searching are listed
+const searchResultCount = 10
+
+func searchCommandOption() *discord.SubcommandOption {
+	return &discord.SubcommandOption{
+		OptionName:  "search",
+		Description: "search for reddit posts",
+		Options: []discord.CommandOptionValue{
+			&discord.StringOption{
+				OptionName:  "query",
+				Description: "what to search for",
+				Required:    true,
+			},
+			&discord.StringOption{
+				OptionName:   "sub",
+				Description:  "only search this subreddit",
+				Autocomplete: true,
+			},
+			&discord.StringOption{
+				OptionName:  "sort",
+				Description: "how to sort the posts found, defaults to relevance",
+				Choices: []discord.StringChoice{
+					{Name: "relevance", Value: "relevance"},
+					{Name: "top", Value: "top"},
+					{Name: "new", Value: "new"},
+					{Name: "comments", Value: "comments"},
+				},
+			},
+			&discord.StringOption{
+				OptionName:  "time",
+				Description: "only find posts from within this time range",
+				Choices:     timeRanges,
+			},
+		},
+	}
+}
+
+// search lists the posts found by searching reddit that may be posted in the channel
+func (rh *redditHandler) search(
+	event *gateway.InteractionCreateEvent,
+	options map[string]discord.CommandInteractionOption,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	query := strings.TrimSpace(options["query"].String())
+	if query == "" {
+		err = errors.New("no query given")
+		return
+	}
+
+	subreddit := strings.TrimPrefix(strings.TrimSpace(options["sub"].String()), "r/")
+	if subreddit != "" {
+		err = rh.content.checkSubreddit(subreddit)
+		if err != nil {
+			return
+		}
+	}
+
+	ch, err := rh.srv.Session.Channel(event.ChannelID)
+	if err != nil {
+		err = fmt.Errorf("getting channel: %v", err)
+		return
+	}
+
+	posts, err := rh.interactionClient.search(
+		query, subreddit, options["sort"].String(), options["time"].String())
+	if err != nil {
+		err = fmt.Errorf("searching reddit: %v", err)
+		return
+	}
+
+	if subreddit != "" {
+		rh.recordSubreddit(subreddit)
+	}
+
+	lines := []string{}
+	for _, post := range posts {
+		if rh.content.checkSubreddit(post.Subreddit) != nil || (post.IsNSFW && !ch.NSFW) {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("%d. [%s](https://reddit.com%s) r/%s ⬆%d",
+			len(lines)+1, escapeLinkText(post.Title), post.Permalink, post.Subreddit, post.Ups))
+		if len(lines) == searchResultCount {
+			break
+		}
+	}
+
+	description := strings.Join(lines, "\n")
+	if description == "" {
+		description = "found no posts"
+	}
+
+	title := fmt.Sprintf("Reddit posts matching %q", query)
+	if subreddit != "" {
+		title += " in r/" + subreddit
+	}
+
+	response = &api.InteractionResponseData{
+		Embeds: &[]discord.Embed{{
+			Title:       command.Truncate(title, 256),
+			Description: command.Truncate(description, 4096),
+		}},
+	}
+	return
+}
+
+// escapeLinkText makes the text usable as the text of a markdown link
+func escapeLinkText(text string) string {
+	return strings.NewReplacer("[", "(", "]", ")").Replace(text)
+}
+
+// search gets the posts matching the query sorted by sort, within the subreddit if it is not empty
+func (c *client) search(query string, subreddit string, sort string, timeRange string) (
+	[]*submission, error,
+) {
+	values := url.Values{}
+	values.Set("q", query)
+	values.Set("limit", strconv.Itoa(searchResultCount*2))
+	values.Set("raw_json", "1")
+	if sort != "" {
+		values.Set("sort", sort)
+	}
+	if timeRange != "" {
+		values.Set("t", timeRange)
+	}
+
+	path := "/search"
+	if subreddit != "" {
+		path = fmt.Sprintf("/r/%s/search", url.PathEscape(subreddit))
+		values.Set("restrict_sr", "1")
+	}
+
+	var r listing
+	err := c.get(path, values, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]*submission, len(r.Data.Children))
+	for i, child := range r.Data.Children {
+		posts[i] = child.Data
+	}
+	return posts, nil
+}
@@ -0,0 +1,317 @@
+package reddit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/polarbirds/lunde/internal/scheduler"
+	"gopkg.in/robfig/cron.v2"
+)
+
+const (
+	subscriptionsStoreName = "redditsubscriptions"
+	// subscriptionFetchCount is how many posts are fetched each time a subreddit is polled
+	subscriptionFetchCount = 25
+	// maxPostedIDs is how many posted posts are remembered per subscription. It must be larger
+	// than subscriptionFetchCount for posts to not be posted again
+	maxPostedIDs         = 200
+	defaultPollInterval  = 30 * time.Minute
+	minimumPollInterval  = 5 * time.Minute
+	subscriptionJobNamef = "reddit-subscription-%d"
+)
+
+// subscription posts new posts from Subreddit sorted by Sort in ChannelID, polling every Interval
+type subscription struct {
+	ID        int               `json:"id"`
+	Subreddit string            `json:"subreddit"`
+	Sort      string            `json:"sort"`
+	ChannelID discord.ChannelID `json:"channelID"`
+	Interval  time.Duration     `json:"interval"`
+	MinScore  int               `json:"minScore"`
+	// Posted holds the IDs of the posts already posted, newest last
+	Posted []string `json:"posted"`
+}
+
+type subscriptions struct {
+	NextID        int             `json:"nextID"`
+	Subscriptions []*subscription `json:"subscriptions"`
+}
+
+func subscribeCommandOption() *discord.SubcommandOption {
+	return &discord.SubcommandOption{
+		OptionName:  "subscribe",
+		Description: "post new posts from a subreddit in a channel",
+		Options: []discord.CommandOptionValue{
+			&discord.StringOption{
+				OptionName:   "sub",
+				Description:  "what subreddit to post from",
+				Required:     true,
+				Autocomplete: true,
+			},
+			&discord.StringOption{
+				OptionName:  "sort",
+				Description: "what listing to look for new posts in",
+				Required:    true,
+				Choices: []discord.StringChoice{
+					{Name: "hot", Value: "hot"},
+					{Name: "new", Value: "new"},
+					{Name: "top", Value: "top"},
+					{Name: "rising", Value: "rising"},
+				},
+			},
+			&discord.ChannelOption{
+				OptionName:  "channel",
+				Description: "channel to post in",
+				Required:    true,
+			},
+			&discord.StringOption{
+				OptionName:  "interval",
+				Description: "how often to look for new posts, like 30m or 2h, defaults to 30m",
+			},
+			&discord.IntegerOption{
+				OptionName:  "min_score",
+				Description: "only post posts with at least this many upvotes",
+			},
+		},
+	}
+}
+
+func (rh *redditHandler) loadSubscriptions() error {
+	err := rh.srv.Store.Load(subscriptionsStoreName, &rh.subscriptions)
+	if err != nil {
+		return fmt.Errorf("loading reddit subscriptions: %v", err)
+	}
+
+	for _, sub := range rh.subscriptions.Subscriptions {
+		err = rh.registerSubscription(sub)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rh *redditHandler) subscribe(
+	event *gateway.InteractionCreateEvent,
+	options map[string]discord.CommandInteractionOption,
+) (
+	msg string, err error,
+) {
+	channelFlake, err := options["channel"].SnowflakeValue()
+	if err != nil {
+		err = fmt.Errorf("parsing channel as flake: %v", err)
+		return
+	}
+
+	sub := &subscription{
+		Subreddit: strings.TrimPrefix(strings.TrimSpace(options["sub"].String()), "r/"),
+		Sort:      options["sort"].String(),
+		ChannelID: discord.ChannelID(channelFlake),
+		Interval:  defaultPollInterval,
+	}
+	if sub.Subreddit == "" {
+		err = errors.New("no subreddit given")
+		return
+	}
+
+	err = rh.content.checkSubreddit(sub.Subreddit)
+	if err != nil {
+		return
+	}
+
+	if interval := options["interval"].String(); interval != "" {
+		sub.Interval, err = time.ParseDuration(interval)
+		if err != nil {
+			err = fmt.Errorf("parsing interval: %v", err)
+			return
+		}
+	}
+	if sub.Interval < minimumPollInterval {
+		err = fmt.Errorf("interval must be at least %s", minimumPollInterval)
+		return
+	}
+
+	minScore, err := options["min_score"].IntValue()
+	if err != nil {
+		err = fmt.Errorf("parsing min_score as int: %v", err)
+		return
+	}
+	sub.MinScore = int(minScore)
+
+	// only posts appearing after subscribing are posted, so subscribing does not flood the channel
+	submissions, err := rh.interactionClient.getSubredditSubmissions(
+		sub.Subreddit, sub.Sort, "", subscriptionFetchCount, subscriptionFetchCount)
+	if err != nil {
+		err = fmt.Errorf("getting posts from r/%s: %v", sub.Subreddit, err)
+		return
+	}
+	for _, submission := range submissions {
+		sub.Posted = append(sub.Posted, submission.ID)
+	}
+
+	rh.recordSubreddit(sub.Subreddit)
+
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	sub.ID = rh.subscriptions.NextID
+	err = rh.registerSubscription(sub)
+	if err != nil {
+		return
+	}
+
+	rh.subscriptions.NextID++
+	rh.subscriptions.Subscriptions = append(rh.subscriptions.Subscriptions, sub)
+	err = rh.saveSubscriptions()
+	if err != nil {
+		return
+	}
+
+	msg = fmt.Sprintf("subscribed %s to new posts from r/%s every %s, subscription %d",
+		sub.ChannelID.Mention(), sub.Subreddit, sub.Interval, sub.ID)
+	return
+}
+
+func (rh *redditHandler) unsubscribe(options map[string]discord.CommandInteractionOption) (
+	msg string, err error,
+) {
+	id, err := options["id"].IntValue()
+	if err != nil {
+		err = fmt.Errorf("parsing id as int: %v", err)
+		return
+	}
+
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	for i, sub := range rh.subscriptions.Subscriptions {
+		if int64(sub.ID) != id {
+			continue
+		}
+
+		rh.srv.Scheduler.Remove(fmt.Sprintf(subscriptionJobNamef, sub.ID))
+		subs := rh.subscriptions.Subscriptions
+		rh.subscriptions.Subscriptions = append(subs[:i], subs[i+1:]...)
+		err = rh.saveSubscriptions()
+		if err != nil {
+			return
+		}
+
+		msg = fmt.Sprintf("unsubscribed %s from r/%s", sub.ChannelID.Mention(), sub.Subreddit)
+		return
+	}
+
+	err = fmt.Errorf("found no subscription with ID %d", id)
+	return
+}
+
+func (rh *redditHandler) listSubscriptions() string {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	if len(rh.subscriptions.Subscriptions) == 0 {
+		return "there are no subreddit subscriptions"
+	}
+
+	lines := []string{}
+	for _, sub := range rh.subscriptions.Subscriptions {
+		line := fmt.Sprintf("%d: r/%s (%s) in %s every %s",
+			sub.ID, sub.Subreddit, sub.Sort, sub.ChannelID.Mention(), sub.Interval)
+		if sub.MinScore > 0 {
+			line += fmt.Sprintf(", at least ⬆%d", sub.MinScore)
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (rh *redditHandler) registerSubscription(sub *subscription) error {
+	return rh.srv.Scheduler.Add(scheduler.Job{
+		Name: fmt.Sprintf(subscriptionJobNamef, sub.ID),
+		Description: fmt.Sprintf("post new posts from r/%s in %s", sub.Subreddit,
+			sub.ChannelID.Mention()),
+		Schedule: cron.Every(sub.Interval),
+		Run:      func() error { return rh.poll(sub) },
+	})
+}
+
+// poll posts the posts of the subscribed subreddit that are not posted yet and have a high enough
+// score
+func (rh *redditHandler) poll(sub *subscription) error {
+	submissions, err := rh.client.getSubredditSubmissions(
+		sub.Subreddit, sub.Sort, "", subscriptionFetchCount, subscriptionFetchCount)
+	if err != nil {
+		return fmt.Errorf("getting posts from r/%s: %v", sub.Subreddit, err)
+	}
+
+	ch, err := rh.srv.Session.Channel(sub.ChannelID)
+	if err != nil {
+		return fmt.Errorf("getting channel: %v", err)
+	}
+
+	rh.mutex.Lock()
+	posted := make(map[string]bool, len(sub.Posted))
+	for _, id := range sub.Posted {
+		posted[id] = true
+	}
+	rh.mutex.Unlock()
+
+	// post without holding the mutex, so slow requests to discord do not hold up the commands
+	sent := []string{}
+	for _, submission := range submissions {
+		if posted[submission.ID] || submission.Ups < sub.MinScore {
+			continue
+		}
+
+		content, embeds, ok := rh.channelMessage(submission, ch)
+		if !ok {
+			continue
+		}
+
+		_, err = rh.srv.Session.SendMessageComplex(sub.ChannelID, api.SendMessageData{
+			Content:         content,
+			Embeds:          embeds,
+			AllowedMentions: &api.AllowedMentions{},
+		})
+		if err != nil {
+			err = fmt.Errorf("posting %s: %v", submission.Permalink, err)
+			break
+		}
+
+		sent = append(sent, submission.ID)
+	}
+
+	if len(sent) == 0 {
+		return err
+	}
+
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	sub.Posted = append(sub.Posted, sent...)
+	if len(sub.Posted) > maxPostedIDs {
+		sub.Posted = sub.Posted[len(sub.Posted)-maxPostedIDs:]
+	}
+
+	saveErr := rh.saveSubscriptions()
+	if err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// saveSubscriptions persists the subscriptions, and must be called with the mutex held
+func (rh *redditHandler) saveSubscriptions() error {
+	err := rh.srv.Store.Save(subscriptionsStoreName, rh.subscriptions)
+	if err != nil {
+		return fmt.Errorf("saving reddit subscriptions: %v", err)
+	}
+	return nil
+}
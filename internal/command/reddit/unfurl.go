@@ -0,0 +1,199 @@
+package reddit
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	unfurlStoreName = "redditunfurl"
+	// maxUnfurls is how many posts linked in one message are unfurled
+	maxUnfurls = 3
+)
+
+// postLinkPattern matches links to reddit posts, capturing the ID of the post. Links wrapped in <>
+// are not matched, as discord does not embed them either
+var postLinkPattern = regexp.MustCompile(`(?:^|[^<])https?://(?:(?:www|old|new|np)\.)?` +
+	`(?:reddit\.com/r/\w+/comments/(\w+)|redd\.it/(\w+))`)
+
+// unfurlSettings holds the channels reddit links are not unfurled in
+type unfurlSettings struct {
+	Disabled map[discord.ChannelID]bool `json:"disabled"`
+}
+
+func unfurlCommandOption() *discord.SubcommandOption {
+	return &discord.SubcommandOption{
+		OptionName:  "unfurl",
+		Description: "turn showing the posts of reddit links posted in a channel on or off",
+		Options: []discord.CommandOptionValue{
+			&discord.BooleanOption{
+				OptionName:  "enabled",
+				Description: "whether to show the posts of reddit links",
+				Required:    true,
+			},
+			&discord.ChannelOption{
+				OptionName:  "channel",
+				Description: "channel to turn it on or off in, defaults to this channel",
+			},
+		},
+	}
+}
+
+func (rh *redditHandler) loadUnfurlSettings() error {
+	err := rh.srv.Store.Load(unfurlStoreName, &rh.unfurl)
+	if err != nil {
+		return fmt.Errorf("loading reddit unfurl settings: %v", err)
+	}
+	if rh.unfurl.Disabled == nil {
+		rh.unfurl.Disabled = map[discord.ChannelID]bool{}
+	}
+	return nil
+}
+
+func (rh *redditHandler) setUnfurl(
+	event *gateway.InteractionCreateEvent,
+	options map[string]discord.CommandInteractionOption,
+) (
+	msg string, err error,
+) {
+	enabled, err := options["enabled"].BoolValue()
+	if err != nil {
+		err = fmt.Errorf("parsing enabled as bool: %v", err)
+		return
+	}
+
+	channelID := event.ChannelID
+	if _, exists := options["channel"]; exists {
+		var channelFlake discord.Snowflake
+		channelFlake, err = options["channel"].SnowflakeValue()
+		if err != nil {
+			err = fmt.Errorf("parsing channel as flake: %v", err)
+			return
+		}
+		channelID = discord.ChannelID(channelFlake)
+	}
+
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	if enabled {
+		delete(rh.unfurl.Disabled, channelID)
+	} else {
+		rh.unfurl.Disabled[channelID] = true
+	}
+
+	err = rh.srv.Store.Save(unfurlStoreName, rh.unfurl)
+	if err != nil {
+		err = fmt.Errorf("saving reddit unfurl settings: %v", err)
+		return
+	}
+
+	if enabled {
+		msg = fmt.Sprintf("showing the posts of reddit links in %s", channelID.Mention())
+	} else {
+		msg = fmt.Sprintf("no longer showing the posts of reddit links in %s",
+			channelID.Mention())
+	}
+	return
+}
+
+func (rh *redditHandler) handleMessageCreate(ev *gateway.MessageCreateEvent) {
+	if ev.Author.Bot {
+		return
+	}
+
+	ids := postIDs(ev.Content)
+	if len(ids) == 0 {
+		return
+	}
+
+	rh.mutex.Lock()
+	disabled := rh.unfurl.Disabled[ev.ChannelID]
+	rh.mutex.Unlock()
+	if disabled {
+		return
+	}
+
+	// reddit may be slow to respond, so other handlers are not held up
+	go func() {
+		err := rh.unfurlPosts(ev, ids)
+		if err != nil {
+			logrus.Errorf("error occurred unfurling reddit link: %v", err)
+		}
+	}()
+}
+
+// unfurlPosts replies to the message with the posts with the given IDs that may be posted in the
+// channel
+func (rh *redditHandler) unfurlPosts(ev *gateway.MessageCreateEvent, ids []string) error {
+	ch, err := rh.srv.Session.Channel(ev.ChannelID)
+	if err != nil {
+		return fmt.Errorf("getting channel: %v", err)
+	}
+
+	for _, id := range ids {
+		post, err := rh.client.getPostByID(id)
+		if err != nil {
+			return fmt.Errorf("getting post %s: %v", id, err)
+		}
+
+		content, embeds, ok := rh.channelMessage(post, ch)
+		if !ok {
+			continue
+		}
+
+		_, err = rh.srv.Session.SendMessageComplex(ev.ChannelID, api.SendMessageData{
+			Content:         content,
+			Embeds:          embeds,
+			Reference:       &discord.MessageReference{MessageID: ev.ID},
+			AllowedMentions: &api.AllowedMentions{},
+		})
+		if err != nil {
+			return fmt.Errorf("replying with post %s: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// postIDs returns the IDs of the posts linked in the text, without duplicates
+func postIDs(text string) (ids []string) {
+	seen := map[string]bool{}
+	for _, match := range postLinkPattern.FindAllStringSubmatch(text, -1) {
+		id := match[1]
+		if id == "" {
+			id = match[2]
+		}
+
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		ids = append(ids, id)
+		if len(ids) == maxUnfurls {
+			break
+		}
+	}
+	return
+}
+
+// getPostByID gets the post with the given ID
+func (c *client) getPostByID(id string) (*submission, error) {
+	var r listing
+	err := c.get("/by_id/t3_"+url.PathEscape(id), url.Values{"raw_json": {"1"}}, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Data.Children) == 0 {
+		return nil, fmt.Errorf("found no post with ID %q", id)
+	}
+	return r.Data.Children[0].Data, nil
+}
@@ -0,0 +1,66 @@
+package reddit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPostIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "post link",
+			text: "https://www.reddit.com/r/golang/comments/abc123/a_post/",
+			want: []string{"abc123"},
+		},
+		{
+			name: "link in a sentence",
+			text: "look at this https://reddit.com/r/golang/comments/abc123 it is great",
+			want: []string{"abc123"},
+		},
+		{
+			name: "old and new reddit",
+			text: "http://old.reddit.com/r/golang/comments/abc https://new.reddit.com/r/go/comments/def",
+			want: []string{"abc", "def"},
+		},
+		{
+			name: "short link",
+			text: "https://redd.it/xyz789",
+			want: []string{"xyz789"},
+		},
+		{
+			name: "duplicates",
+			text: "https://redd.it/abc https://www.reddit.com/r/golang/comments/abc/a_post",
+			want: []string{"abc"},
+		},
+		{
+			name: "wrapped in <>",
+			text: "<https://www.reddit.com/r/golang/comments/abc123/a_post/>",
+		},
+		{
+			name: "subreddit link",
+			text: "https://www.reddit.com/r/golang",
+		},
+		{
+			name: "other site",
+			text: "https://example.com/r/golang/comments/abc123",
+		},
+		{
+			name: "capped",
+			text: "https://redd.it/a https://redd.it/b https://redd.it/c https://redd.it/d",
+			want: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := postIDs(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got post IDs %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package remind
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/httputil"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/polarbirds/lunde/internal/command"
+	"github.com/polarbirds/lunde/internal/scheduler"
+	"github.com/polarbirds/lunde/internal/server"
+	"github.com/polarbirds/lunde/internal/when"
+	"gopkg.in/robfig/cron.v2"
+)
+
+const storeName = "reminders"
+
+// checkInterval is how often due reminders are looked for
+const checkInterval = 30 * time.Second
+
+// maxAttempts is how many times sending a reminder may fail before it is dropped, which is an hour
+// of checks
+const maxAttempts = 120
+
+type reminder struct {
+	ID        int               `json:"id"`
+	UserID    discord.UserID    `json:"userID"`
+	ChannelID discord.ChannelID `json:"channelID"`
+	Text      string            `json:"text"`
+	At        time.Time         `json:"at"`
+	// DM makes the reminder be sent as a direct message instead of in ChannelID
+	DM bool `json:"dm"`
+	// Attempts is how many times sending the reminder has failed
+	Attempts int `json:"attempts,omitempty"`
+}
+
+type reminders struct {
+	NextID    int        `json:"nextID"`
+	Reminders []reminder `json:"reminders"`
+}
+
+type remindHandler struct {
+	srv   *server.Server
+	data  reminders
+	mutex sync.Mutex
+}
+
+// CreateCommands returns the creators of the remind command, to be reminded of something later,
+// and the reminders command, to list and cancel reminders. The commands share a handler, which
+// registers a job sending the reminders when they are due. Reminders are persisted, so reminders
+// due while the bot was down are sent when it starts
+func CreateCommands() (createRemind server.CreateCommand, createReminders server.CreateCommand) {
+	var rh *remindHandler
+	handler := func(srv *server.Server) (*remindHandler, error) {
+		if rh != nil {
+			return rh, nil
+		}
+
+		created, err := newRemindHandler(srv)
+		if err != nil {
+			return nil, err
+		}
+		rh = created
+		return rh, nil
+	}
+
+	createRemind = func(srv *server.Server) (cmd command.LundeCommand, err error) {
+		rh, err := handler(srv)
+		if err != nil {
+			return
+		}
+
+		cmd = command.LundeCommand{
+			HandleInteraction: rh.handleRemind,
+			CommandData: api.CreateCommandData{
+				Name:        "remind",
+				Description: "get reminded of something later",
+				Options: []discord.CommandOption{
+					&discord.StringOption{
+						OptionName: "when",
+						Description: "when to be reminded, like \"in 2h\", " +
+							"\"tomorrow 09:00\" or \"friday\"",
+						Required: true,
+					},
+					&discord.StringOption{
+						OptionName:  "what",
+						Description: "what to be reminded of",
+						Required:    true,
+					},
+					&discord.BooleanOption{
+						OptionName:  "dm",
+						Description: "remind in a direct message instead of this channel",
+					},
+				},
+			},
+		}
+		return
+	}
+
+	createReminders = func(srv *server.Server) (cmd command.LundeCommand, err error) {
+		rh, err := handler(srv)
+		if err != nil {
+			return
+		}
+
+		cmd = command.LundeCommand{
+			HandleInteraction: rh.handleReminders,
+			CommandData: api.CreateCommandData{
+				Name:        "reminders",
+				Description: "manage your reminders",
+				Options: []discord.CommandOption{
+					&discord.SubcommandOption{
+						OptionName:  "list",
+						Description: "list your reminders",
+					},
+					&discord.SubcommandOption{
+						OptionName:  "cancel",
+						Description: "cancel one of your reminders",
+						Options: []discord.CommandOptionValue{
+							&discord.IntegerOption{
+								OptionName:  "id",
+								Description: "ID of the reminder, as shown by /reminders list",
+								Required:    true,
+							},
+						},
+					},
+				},
+			},
+		}
+		return
+	}
+
+	return
+}
+
+func newRemindHandler(srv *server.Server) (rh *remindHandler, err error) {
+	rh = &remindHandler{srv: srv, data: reminders{NextID: 1}}
+	err = srv.Store.Load(storeName, &rh.data)
+	if err != nil {
+		err = fmt.Errorf("loading reminders: %v", err)
+		return
+	}
+
+	err = srv.Scheduler.Add(scheduler.Job{
+		Name:        "reminders",
+		Description: "send due reminders",
+		Schedule:    cron.Every(checkInterval),
+		Run:         rh.sendDue,
+	})
+	return
+}
+
+func (rh *remindHandler) handleRemind(
+	event *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	msg, err := rh.add(event, options)
+	if err != nil {
+		return
+	}
+
+	response = &api.InteractionResponseData{
+		Content:         option.NewNullableString(msg),
+		AllowedMentions: &api.AllowedMentions{},
+	}
+	return
+}
+
+func (rh *remindHandler) handleReminders(
+	event *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	subcommand, subOptions, err := command.Subcommand(options)
+	if err != nil {
+		return
+	}
+
+	var msg string
+	switch subcommand {
+	case "list":
+		msg = rh.list(event.Member.User.ID)
+	case "cancel":
+		msg, err = rh.cancel(event.Member.User.ID, subOptions)
+	default:
+		err = fmt.Errorf("unknown subcommand %q", subcommand)
+	}
+	if err != nil {
+		return
+	}
+
+	response = &api.InteractionResponseData{
+		Content:         option.NewNullableString(msg),
+		AllowedMentions: &api.AllowedMentions{},
+	}
+	return
+}
+
+func (rh *remindHandler) add(
+	event *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	msg string, err error,
+) {
+	at, err := when.Parse(options["when"].String(), time.Now().In(rh.srv.Location))
+	if err != nil {
+		return
+	}
+
+	text := strings.TrimSpace(options["what"].String())
+	if text == "" {
+		err = errors.New("nothing to be reminded of given")
+		return
+	}
+
+	dm := false
+	if dmOption, exists := options["dm"]; exists {
+		dm, err = dmOption.BoolValue()
+		if err != nil {
+			err = fmt.Errorf("parsing dm as bool: %v", err)
+			return
+		}
+	}
+
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	r := reminder{
+		ID:        rh.data.NextID,
+		UserID:    event.Member.User.ID,
+		ChannelID: event.ChannelID,
+		Text:      text,
+		At:        at,
+		DM:        dm,
+	}
+	rh.data.NextID++
+	rh.data.Reminders = append(rh.data.Reminders, r)
+
+	err = rh.save()
+	if err != nil {
+		// forget the reminder rather than send one the user was told failed
+		rh.data.NextID--
+		rh.data.Reminders = rh.data.Reminders[:len(rh.data.Reminders)-1]
+		return
+	}
+
+	msg = fmt.Sprintf("I will remind you <t:%d:F> (<t:%d:R>), reminder %d",
+		at.Unix(), at.Unix(), r.ID)
+	return
+}
+
+func (rh *remindHandler) list(userID discord.UserID) string {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	lines := []string{}
+	for _, r := range rh.data.Reminders {
+		if r.UserID == userID {
+			lines = append(lines, fmt.Sprintf("%d: <t:%d:F> %s", r.ID, r.At.Unix(), r.Text))
+		}
+	}
+
+	if len(lines) == 0 {
+		return "you have no reminders"
+	}
+
+	msg := command.Truncate(strings.Join(lines, "\n"), 1999)
+	return msg
+}
+
+func (rh *remindHandler) cancel(
+	userID discord.UserID, options map[string]discord.CommandInteractionOption,
+) (
+	msg string, err error,
+) {
+	id, err := options["id"].IntValue()
+	if err != nil {
+		err = fmt.Errorf("parsing id as int: %v", err)
+		return
+	}
+
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	for i, r := range rh.data.Reminders {
+		if int64(r.ID) != id || r.UserID != userID {
+			continue
+		}
+
+		rh.data.Reminders = append(rh.data.Reminders[:i], rh.data.Reminders[i+1:]...)
+		err = rh.save()
+		if err != nil {
+			return
+		}
+
+		msg = fmt.Sprintf("cancelled reminder %d", id)
+		return
+	}
+
+	err = fmt.Errorf("you have no reminder with ID %d", id)
+	return
+}
+
+// sendDue sends and forgets the reminders that are due. Reminders that fail to send are kept and
+// retried on the next check, unless Discord refuses them, like when the channel is deleted or the
+// user does not accept direct messages, or they have failed maxAttempts times
+func (rh *remindHandler) sendDue() error {
+	now := time.Now()
+	due := []reminder{}
+	rh.mutex.Lock()
+	for _, r := range rh.data.Reminders {
+		if !r.At.After(now) {
+			due = append(due, r)
+		}
+	}
+	rh.mutex.Unlock()
+
+	if len(due) == 0 {
+		return nil
+	}
+
+	// send without holding the mutex, so slow requests to discord do not hold up the commands
+	sort.Slice(due, func(i, j int) bool { return due[i].At.Before(due[j].At) })
+	sendErrs := map[int]error{}
+	for _, r := range due {
+		sendErrs[r.ID] = rh.send(r)
+	}
+
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	kept := []reminder{}
+	var errs []string
+	for _, r := range rh.data.Reminders {
+		err, sent := sendErrs[r.ID]
+		if !sent {
+			kept = append(kept, r)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+
+		r.Attempts++
+		if permanent(err) || r.Attempts >= maxAttempts {
+			errs = append(errs, fmt.Sprintf("dropped reminder %d after %d attempt(s): %v",
+				r.ID, r.Attempts, err))
+			continue
+		}
+
+		errs = append(errs, fmt.Sprintf("reminder %d: %v", r.ID, err))
+		kept = append(kept, r)
+	}
+
+	rh.data.Reminders = kept
+	err := rh.save()
+	if err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("sending reminders: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// permanent returns whether the error is Discord refusing a request, which retrying will not fix
+func permanent(err error) bool {
+	var httpErr *httputil.HTTPError
+	return errors.As(err, &httpErr) && httpErr.Status >= 400 && httpErr.Status < 500 &&
+		httpErr.Status != httputil.StatusTooManyRequests
+}
+
+func (rh *remindHandler) send(r reminder) error {
+	channelID := r.ChannelID
+	if r.DM {
+		dm, err := rh.srv.Session.CreatePrivateChannel(r.UserID)
+		if err != nil {
+			return fmt.Errorf("creating private channel: %w", err)
+		}
+		channelID = dm.ID
+	}
+
+	_, err := rh.srv.Session.SendMessageComplex(channelID, api.SendMessageData{
+		Content: fmt.Sprintf("%s reminder: %s", r.UserID.Mention(), r.Text),
+		AllowedMentions: &api.AllowedMentions{
+			Users: []discord.UserID{r.UserID},
+		},
+	})
+	return err
+}
+
+// save persists the reminders, and must be called with the mutex held
+func (rh *remindHandler) save() error {
+	err := rh.srv.Store.Save(storeName, rh.data)
+	if err != nil {
+		return fmt.Errorf("saving reminders: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,412 @@
+package schedule
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/polarbirds/lunde/internal/calendar"
+	"github.com/polarbirds/lunde/internal/command"
+	"github.com/polarbirds/lunde/internal/scheduler"
+	"github.com/polarbirds/lunde/internal/server"
+	"github.com/polarbirds/lunde/internal/tmplfuncs"
+	"github.com/polarbirds/lunde/internal/when"
+	"gopkg.in/robfig/cron.v2"
+)
+
+const storeName = "scheduledmessages"
+
+// message is posted in ChannelID on the schedule given by Cron, or once At. Text and Title are Go
+// templates executed with templateData. Messages with a Title are posted as embeds
+type message struct {
+	ID        int               `json:"id"`
+	ChannelID discord.ChannelID `json:"channelID"`
+	Text      string            `json:"text"`
+	Title     string            `json:"title"`
+	Color     discord.Color     `json:"color"`
+	Cron      string            `json:"cron"`
+	At        time.Time         `json:"at"`
+	CreatedBy discord.UserID    `json:"createdBy"`
+}
+
+type messages struct {
+	NextID   int       `json:"nextID"`
+	Messages []message `json:"messages"`
+}
+
+// templateData is what the templates of scheduled messages have access to
+type templateData struct {
+	Now     time.Time
+	Week    int
+	Holiday string
+}
+
+type scheduleHandler struct {
+	srv   *server.Server
+	data  messages
+	mutex sync.Mutex
+}
+
+// CreateCommand creates a lunde command for admins to post messages on a schedule, and schedules
+// the messages scheduled before
+func CreateCommand(srv *server.Server) (cmd command.LundeCommand, err error) {
+	sh := scheduleHandler{srv: srv, data: messages{NextID: 1}}
+	err = srv.Store.Load(storeName, &sh.data)
+	if err != nil {
+		err = fmt.Errorf("loading scheduled messages: %v", err)
+		return
+	}
+
+	for _, msg := range sh.data.Messages {
+		err = sh.register(msg)
+		if err != nil {
+			err = fmt.Errorf("scheduling message %d: %v", msg.ID, err)
+			return
+		}
+	}
+
+	cmd = command.LundeCommand{
+		HandleInteraction: sh.handleInteraction,
+		CommandData: api.CreateCommandData{
+			Name:                     "schedule",
+			Description:              "post messages on a schedule",
+			DefaultMemberPermissions: discord.NewPermissions(discord.PermissionManageGuild),
+			Options: []discord.CommandOption{
+				&discord.SubcommandOption{
+					OptionName: "message",
+					Description: "post a message once or repeatedly, " +
+						"templates have .Now, .Week, .Holiday and daysUntil",
+					Options: []discord.CommandOptionValue{
+						&discord.ChannelOption{
+							OptionName:  "channel",
+							Description: "channel to post in",
+							Required:    true,
+						},
+						&discord.StringOption{
+							OptionName:  "text",
+							Description: "text of the message, a Go template",
+							Required:    true,
+						},
+						&discord.StringOption{
+							OptionName:  "cron",
+							Description: "cron expression to post repeatedly on, like \"0 9 * * 1\"",
+						},
+						&discord.StringOption{
+							OptionName:  "when",
+							Description: "when to post once, like \"in 2h\" or \"friday 16:00\"",
+						},
+						&discord.StringOption{
+							OptionName:  "title",
+							Description: "post as an embed with this title, a Go template",
+						},
+						&discord.IntegerOption{
+							OptionName:  "color",
+							Description: "colour of the embed as a number, like 16711680 for red",
+						},
+					},
+				},
+				&discord.SubcommandOption{
+					OptionName:  "list",
+					Description: "list the scheduled messages",
+				},
+				&discord.SubcommandOption{
+					OptionName:  "remove",
+					Description: "stop posting a scheduled message",
+					Options: []discord.CommandOptionValue{
+						&discord.IntegerOption{
+							OptionName:  "id",
+							Description: "ID of the message, as shown by /schedule list",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return
+}
+
+func (sh *scheduleHandler) handleInteraction(
+	event *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	response *api.InteractionResponseData, err error,
+) {
+	subcommand, subOptions, err := command.Subcommand(options)
+	if err != nil {
+		return
+	}
+
+	var msg string
+	switch subcommand {
+	case "message":
+		msg, err = sh.add(event, subOptions)
+	case "list":
+		msg = sh.list()
+	case "remove":
+		msg, err = sh.remove(subOptions)
+	default:
+		err = fmt.Errorf("unknown subcommand %q", subcommand)
+	}
+	if err != nil {
+		return
+	}
+
+	response = &api.InteractionResponseData{
+		Content:         option.NewNullableString(msg),
+		AllowedMentions: &api.AllowedMentions{},
+	}
+	return
+}
+
+func (sh *scheduleHandler) add(
+	event *gateway.InteractionCreateEvent, options map[string]discord.CommandInteractionOption,
+) (
+	reply string, err error,
+) {
+	channelFlake, err := options["channel"].SnowflakeValue()
+	if err != nil {
+		err = fmt.Errorf("parsing channel as flake: %v", err)
+		return
+	}
+
+	msg := message{
+		ChannelID: discord.ChannelID(channelFlake),
+		Text:      options["text"].String(),
+		Title:     options["title"].String(),
+		Cron:      strings.TrimSpace(options["cron"].String()),
+		CreatedBy: event.Member.User.ID,
+	}
+
+	if colorOption, exists := options["color"]; exists {
+		var color int64
+		color, err = colorOption.IntValue()
+		if err != nil {
+			err = fmt.Errorf("parsing color as int: %v", err)
+			return
+		}
+		msg.Color = discord.Color(color)
+	}
+
+	whenText := strings.TrimSpace(options["when"].String())
+	if (msg.Cron == "") == (whenText == "") {
+		err = errors.New("give either a cron expression to post repeatedly or when to post once")
+		return
+	}
+
+	if whenText != "" {
+		msg.At, err = when.Parse(whenText, time.Now().In(sh.srv.Location))
+		if err != nil {
+			return
+		}
+	}
+
+	// render the message once to catch mistakes in the templates before it is scheduled
+	_, err = sh.render(msg)
+	if err != nil {
+		return
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	msg.ID = sh.data.NextID
+	err = sh.register(msg)
+	if err != nil {
+		return
+	}
+
+	sh.data.NextID++
+	sh.data.Messages = append(sh.data.Messages, msg)
+	err = sh.save()
+	if err != nil {
+		return
+	}
+
+	reply = fmt.Sprintf("scheduled message %d in %s %s", msg.ID, msg.ChannelID.Mention(),
+		describeSchedule(msg))
+	return
+}
+
+func (sh *scheduleHandler) list() string {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if len(sh.data.Messages) == 0 {
+		return "there are no scheduled messages"
+	}
+
+	lines := []string{}
+	for _, msg := range sh.data.Messages {
+		text := msg.Text
+		if msg.Title != "" {
+			text = msg.Title
+		}
+		if len(text) > 50 {
+			text = command.Truncate(text, 50) + "…"
+		}
+
+		lines = append(lines, fmt.Sprintf("%d: %s %s `%s`",
+			msg.ID, msg.ChannelID.Mention(), describeSchedule(msg), text))
+	}
+
+	reply := command.Truncate(strings.Join(lines, "\n"), 1999)
+	return reply
+}
+
+func (sh *scheduleHandler) remove(options map[string]discord.CommandInteractionOption) (
+	reply string, err error,
+) {
+	id, err := options["id"].IntValue()
+	if err != nil {
+		err = fmt.Errorf("parsing id as int: %v", err)
+		return
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if !sh.forget(int(id)) {
+		err = fmt.Errorf("found no scheduled message with ID %d", id)
+		return
+	}
+
+	err = sh.save()
+	if err != nil {
+		return
+	}
+
+	reply = fmt.Sprintf("removed scheduled message %d", id)
+	return
+}
+
+// register adds a job posting the message to the scheduler
+func (sh *scheduleHandler) register(msg message) (err error) {
+	var sched cron.Schedule
+	if msg.Cron != "" {
+		sched, err = cron.Parse(fmt.Sprintf("TZ=%s %s", sh.srv.Location, msg.Cron))
+		if err != nil {
+			return fmt.Errorf("parsing cron expression %q: %v", msg.Cron, err)
+		}
+	} else {
+		sched = scheduler.Once(msg.At)
+	}
+
+	return sh.srv.Scheduler.Add(scheduler.Job{
+		Name:        jobName(msg.ID),
+		Description: fmt.Sprintf("post scheduled message %d", msg.ID),
+		Schedule:    sched,
+		Run:         func() error { return sh.post(msg) },
+		// messages posted once are posted late rather than never
+		CatchUp: msg.Cron == "",
+	})
+}
+
+func (sh *scheduleHandler) post(msg message) error {
+	data, err := sh.render(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = sh.srv.Session.SendMessageComplex(msg.ChannelID, data)
+	if err != nil {
+		return fmt.Errorf("posting scheduled message %d: %v", msg.ID, err)
+	}
+
+	if msg.Cron == "" {
+		sh.mutex.Lock()
+		defer sh.mutex.Unlock()
+
+		sh.forget(msg.ID)
+		return sh.save()
+	}
+
+	return nil
+}
+
+func (sh *scheduleHandler) render(msg message) (data api.SendMessageData, err error) {
+	now := time.Now().In(sh.srv.Location)
+	_, week := now.ISOWeek()
+	tmplData := templateData{Now: now, Week: week}
+	if holiday, isHoliday := calendar.NorwegianHoliday(now); isHoliday {
+		tmplData.Holiday = holiday.Name
+	}
+
+	text, err := sh.execute(msg.Text, tmplData)
+	if err != nil {
+		return
+	}
+
+	data.AllowedMentions = &api.AllowedMentions{
+		Parse: []api.AllowedMentionType{api.AllowUserMention, api.AllowRoleMention},
+	}
+
+	if msg.Title == "" {
+		data.Content = text
+		return
+	}
+
+	title, err := sh.execute(msg.Title, tmplData)
+	if err != nil {
+		return
+	}
+
+	data.Embeds = []discord.Embed{{Title: title, Description: text, Color: msg.Color}}
+	return
+}
+
+func (sh *scheduleHandler) execute(text string, data templateData) (string, error) {
+	now := func() time.Time { return data.Now }
+	tmpl, err := template.New("").Funcs(tmplfuncs.WithDaysUntil(sh.srv.Location, now)).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", fmt.Errorf("executing template: %v", err)
+	}
+
+	return buf.String(), nil
+}
+
+// forget removes the message and its job, and must be called with the mutex held
+func (sh *scheduleHandler) forget(id int) bool {
+	for i, msg := range sh.data.Messages {
+		if msg.ID == id {
+			sh.srv.Scheduler.Remove(jobName(id))
+			sh.data.Messages = append(sh.data.Messages[:i], sh.data.Messages[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// save persists the scheduled messages, and must be called with the mutex held
+func (sh *scheduleHandler) save() error {
+	err := sh.srv.Store.Save(storeName, sh.data)
+	if err != nil {
+		return fmt.Errorf("saving scheduled messages: %v", err)
+	}
+	return nil
+}
+
+func describeSchedule(msg message) string {
+	if msg.Cron != "" {
+		return fmt.Sprintf("on `%s`", msg.Cron)
+	}
+	return fmt.Sprintf("<t:%d:F>", msg.At.Unix())
+}
+
+func jobName(id int) string {
+	return fmt.Sprintf("scheduledmessage-%d", id)
+}
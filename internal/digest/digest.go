@@ -0,0 +1,167 @@
+package digest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/haraldfw/cfger"
+	"github.com/polarbirds/lunde/internal/scheduler"
+	"github.com/polarbirds/lunde/internal/server"
+	"github.com/polarbirds/lunde/internal/stats"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/robfig/cron.v2"
+)
+
+const (
+	mondayAt09CronPattern = "0 9 * * 1"
+	defaultNicePattern    = `(^|\D)69(\D|$)`
+	period                = 7 * 24 * time.Hour
+	// buildDataTimeout is how long the digest waits for the data of the server to be built, which
+	// is still building when a digest missed while the bot was down is caught up on at start
+	buildDataTimeout = 30 * time.Minute
+)
+
+type digestConfig struct {
+	Digest struct {
+		// ChannelID is where the digest is posted, the digest is disabled if not set
+		ChannelID discord.ChannelID `yaml:"channelID"`
+		Cron      string            `yaml:"cron"`
+		// NicePattern matches the messages counted as nice
+		NicePattern string `yaml:"nicePattern"`
+	} `yaml:"digest"`
+}
+
+type digester struct {
+	srv         *server.Server
+	channelID   discord.ChannelID
+	nicePattern *regexp.Regexp
+}
+
+// Register reads the digest section of the config and registers a job posting a summary of the
+// past week
+func Register(srv *server.Server) (err error) {
+	var cfg digestConfig
+	_, err = cfger.ReadStructuredCfgRecursive("env::CONFIG", &cfg)
+	if err != nil {
+		err = fmt.Errorf("reading digest config: %v", err)
+		return
+	}
+
+	if !cfg.Digest.ChannelID.IsValid() {
+		logrus.Info("digest.channelID not set, weekly digest disabled")
+		return
+	}
+
+	if cfg.Digest.Cron == "" {
+		cfg.Digest.Cron = mondayAt09CronPattern
+	}
+	if cfg.Digest.NicePattern == "" {
+		cfg.Digest.NicePattern = defaultNicePattern
+	}
+
+	d := digester{srv: srv, channelID: cfg.Digest.ChannelID}
+	d.nicePattern, err = regexp.Compile(cfg.Digest.NicePattern)
+	if err != nil {
+		err = fmt.Errorf("compiling digest.nicePattern: %v", err)
+		return
+	}
+
+	sched, err := cron.Parse(fmt.Sprintf("TZ=%s %s", srv.Location, cfg.Digest.Cron))
+	if err != nil {
+		err = fmt.Errorf("parsing digest.cron: %v", err)
+		return
+	}
+
+	return srv.Scheduler.Add(scheduler.Job{
+		Name:        "digest",
+		Description: "post a summary of the past week",
+		Schedule:    sched,
+		Run:         d.post,
+		CatchUp:     true,
+	})
+}
+
+func (d *digester) post() error {
+	select {
+	case <-d.srv.DataBuilt():
+	case <-time.After(buildDataTimeout):
+		return fmt.Errorf("building data not done after %s, digest not posted", buildDataTimeout)
+	}
+
+	_, err := d.srv.Session.SendEmbeds(d.channelID, d.embed(time.Now().Add(-period)))
+	if err != nil {
+		return fmt.Errorf("posting digest: %v", err)
+	}
+	return nil
+}
+
+// embed summarises the messages and reactions since the given time
+func (d *digester) embed(since time.Time) discord.Embed {
+	users := map[string]int{}
+	channels := map[string]int{}
+	words := map[string]int{}
+	nice := 0
+
+	records := d.srv.Activity(since)
+	for _, rec := range records {
+		users[rec.AuthorID.Mention()]++
+		channels[rec.ChannelID.Mention()]++
+		if d.nicePattern.MatchString(rec.Content) {
+			nice++
+		}
+
+		// split like the count data, so the counts can be compared
+		for _, word := range strings.Split(rec.Content, " ") {
+			word = strings.TrimSpace(word)
+			if word != "" {
+				words[word]++
+			}
+		}
+	}
+
+	return discord.Embed{
+		Title:       fmt.Sprintf("Week %s", weekString(since.In(d.srv.Location))),
+		Description: fmt.Sprintf("%d messages, %d of them nice", len(records), nice),
+		Fields: []discord.EmbedField{
+			{Name: "Most active users", Value: orNone(stats.TopLines(users)), Inline: true},
+			{Name: "Most active channels", Value: orNone(stats.TopLines(channels)),
+				Inline: true},
+			{Name: "Top new words", Value: orNone(stats.TopLines(d.newWords(words))),
+				Inline: true},
+			{Name: "Most reacted messages", Value: stats.MostReacted(d.srv, since)},
+		},
+	}
+}
+
+// newWords returns the words that were not used before the given words were counted
+func (d *digester) newWords(words map[string]int) map[string]int {
+	d.srv.CountMutex.RLock()
+	defer d.srv.CountMutex.RUnlock()
+
+	newWords := map[string]int{}
+	for word, count := range words {
+		if strings.Contains(word, "://") || strings.HasPrefix(word, "<") {
+			continue
+		}
+
+		if d.srv.CountData[0][word] <= count {
+			newWords[word] = count
+		}
+	}
+	return newWords
+}
+
+func weekString(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d, %d", week, year)
+}
+
+func orNone(lines string) string {
+	if lines == "" {
+		return "none"
+	}
+	return lines
+}
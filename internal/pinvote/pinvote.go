@@ -0,0 +1,195 @@
+package pinvote
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/haraldfw/cfger"
+	"github.com/polarbirds/lunde/internal/command"
+	"github.com/polarbirds/lunde/internal/server"
+	"github.com/sirupsen/logrus"
+)
+
+// maxPins is the maximum amount of pinned messages discord allows in a channel
+const maxPins = 50
+
+type pinVoteConfig struct {
+	PinVote struct {
+		// Threshold is how many reactions with Emoji it takes to pin a message, 0 disables voting
+		Threshold int    `yaml:"threshold"`
+		Emoji     string `yaml:"emoji"`
+		// ArchiveChannelID is where pins are posted when unpinned to make room for new pins
+		ArchiveChannelID discord.ChannelID `yaml:"archiveChannelID"`
+	} `yaml:"pinVote"`
+}
+
+type pinVoter struct {
+	srv              *server.Server
+	threshold        int
+	emoji            discord.APIEmoji
+	archiveChannelID discord.ChannelID
+
+	// channelMutexes serialise pinning in each channel, as votes crossing the threshold at the
+	// same time would otherwise make room and pin twice
+	channelMutexes map[discord.ChannelID]*sync.Mutex
+	mutex          sync.Mutex
+}
+
+// Register reads the pinVote section of the config and starts pinning messages reaching the
+// threshold of pin reactions
+func Register(srv *server.Server) (err error) {
+	var cfg pinVoteConfig
+	_, err = cfger.ReadStructuredCfgRecursive("env::CONFIG", &cfg)
+	if err != nil {
+		err = fmt.Errorf("reading pinvote config: %v", err)
+		return
+	}
+
+	if cfg.PinVote.Threshold <= 0 {
+		logrus.Info("pinVote.threshold not set, pin voting disabled")
+		return
+	}
+
+	if cfg.PinVote.Emoji == "" {
+		cfg.PinVote.Emoji = "📌"
+	}
+
+	pv := &pinVoter{
+		srv:              srv,
+		channelMutexes:   map[discord.ChannelID]*sync.Mutex{},
+		threshold:        cfg.PinVote.Threshold,
+		archiveChannelID: cfg.PinVote.ArchiveChannelID,
+	}
+	pv.emoji, err = command.ParseEmoji(cfg.PinVote.Emoji)
+	if err != nil {
+		err = fmt.Errorf("parsing pinVote.emoji: %v", err)
+		return
+	}
+
+	srv.AddReactionAddHandler(pv.handleReactionAdd)
+
+	return
+}
+
+func (pv *pinVoter) handleReactionAdd(ev *gateway.MessageReactionAddEvent) {
+	if ev.Emoji.APIString() != pv.emoji {
+		return
+	}
+
+	msg, err := pv.srv.Session.Message(ev.ChannelID, ev.MessageID)
+	if err != nil {
+		logrus.Errorf("error occurred getting message %s to count pin votes: %v",
+			ev.MessageID, err)
+		return
+	}
+
+	if msg.Pinned || pv.votes(msg) < pv.threshold {
+		return
+	}
+
+	channelMutex := pv.channelMutex(ev.ChannelID)
+	channelMutex.Lock()
+	defer channelMutex.Unlock()
+
+	// the pins are fetched after the lock is held, so a vote pinning the message meanwhile is seen
+	pins, err := pv.srv.Session.PinnedMessages(ev.ChannelID)
+	if err != nil {
+		logrus.Errorf("error occurred getting pinned messages in channel %s: %v",
+			ev.ChannelID, err)
+		return
+	}
+	for _, pin := range pins {
+		if pin.ID == ev.MessageID {
+			return
+		}
+	}
+
+	err = pv.makeRoom(ev.ChannelID, pins)
+	if err != nil {
+		logrus.Errorf("error occurred making room for pin in channel %s: %v", ev.ChannelID, err)
+		return
+	}
+
+	err = pv.srv.Session.PinMessage(ev.ChannelID, ev.MessageID, "pinned by vote")
+	if err != nil {
+		logrus.Errorf("error occurred pinning message %s: %v", ev.MessageID, err)
+		return
+	}
+	logrus.Infof("pinned message %s in channel %s by vote", ev.MessageID, ev.ChannelID)
+}
+
+func (pv *pinVoter) channelMutex(channelID discord.ChannelID) *sync.Mutex {
+	pv.mutex.Lock()
+	defer pv.mutex.Unlock()
+
+	channelMutex, exists := pv.channelMutexes[channelID]
+	if !exists {
+		channelMutex = &sync.Mutex{}
+		pv.channelMutexes[channelID] = channelMutex
+	}
+	return channelMutex
+}
+
+func (pv *pinVoter) votes(msg *discord.Message) int {
+	for _, r := range msg.Reactions {
+		if r.Emoji.APIString() == pv.emoji {
+			return r.Count
+		}
+	}
+	return 0
+}
+
+// makeRoom unpins the oldest of the pins in the channel if it is full, posting it to the archive
+// channel. It must be called with the mutex of the channel held
+func (pv *pinVoter) makeRoom(channelID discord.ChannelID, pins []discord.Message) (err error) {
+	if len(pins) < maxPins {
+		return nil
+	}
+
+	// pins are returned newest first
+	oldest := pins[len(pins)-1]
+
+	if pv.archiveChannelID.IsValid() {
+		_, err = pv.srv.Session.SendEmbeds(
+			pv.archiveChannelID, archiveEmbed(pv.srv.GuildID, oldest))
+		if err != nil {
+			return fmt.Errorf("archiving message %s: %v", oldest.ID, err)
+		}
+	}
+
+	err = pv.srv.Session.UnpinMessage(channelID, oldest.ID, "making room for pin by vote")
+	if err != nil {
+		return fmt.Errorf("unpinning message %s: %v", oldest.ID, err)
+	}
+
+	logrus.Infof("unpinned message %s in channel %s to make room for pin", oldest.ID, channelID)
+	return nil
+}
+
+func archiveEmbed(guildID discord.GuildID, msg discord.Message) discord.Embed {
+	embed := discord.Embed{
+		Description: msg.Content,
+		Author: &discord.EmbedAuthor{
+			Name: msg.Author.DisplayOrUsername(),
+			Icon: msg.Author.AvatarURL(),
+		},
+		URL: fmt.Sprintf("https://discord.com/channels/%s/%s/%s",
+			guildID, msg.ChannelID, msg.ID),
+		Title:     "Archived pin",
+		Timestamp: msg.Timestamp,
+		Fields: []discord.EmbedField{
+			{Name: "Channel", Value: msg.ChannelID.Mention()},
+		},
+	}
+
+	for _, attachment := range msg.Attachments {
+		if attachment.Height != 0 {
+			embed.Image = &discord.EmbedImage{URL: attachment.URL}
+			break
+		}
+	}
+
+	return embed
+}
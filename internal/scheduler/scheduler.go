@@ -0,0 +1,337 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/polarbirds/lunde/internal/store"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/robfig/cron.v2"
+)
+
+const storeName = "jobs"
+
+// maxMissedRuns caps how many missed runs are looked through to find the last one, so jobs running
+// every second do not make catching up slow after a long downtime
+const maxMissedRuns = 100000
+
+// Job is a named function run on a schedule
+type Job struct {
+	Name        string
+	Description string
+	Schedule    cron.Schedule
+	Run         func() error
+	// CatchUp makes the job run once on start if it was due while the bot was down
+	CatchUp bool
+}
+
+// Once returns a schedule running a job only at the given time
+func Once(at time.Time) cron.Schedule {
+	return once(at)
+}
+
+type once time.Time
+
+// Next implements cron.Schedule
+func (o once) Next(t time.Time) time.Time {
+	if time.Time(o).After(t) {
+		return time.Time(o)
+	}
+	return time.Time{}
+}
+
+// Status describes a registered job
+type Status struct {
+	Name        string
+	Description string
+	Paused      bool
+	LastRun     time.Time
+	NextRun     time.Time
+	LastError   string
+}
+
+// state is the part of the status of a job that is persisted
+type state struct {
+	// Since is when the job last ran, or was first registered or skipped since. Runs due after it
+	// were missed
+	Since     time.Time `json:"since"`
+	LastRun   time.Time `json:"lastRun"`
+	LastError string    `json:"lastError"`
+	Paused    bool      `json:"paused"`
+}
+
+type job struct {
+	Job
+	entryID cron.EntryID
+	running bool
+}
+
+// Scheduler runs named jobs on their schedules, keeping their status so they can be listed,
+// paused and caught up on after restarts
+type Scheduler struct {
+	store   *store.Store
+	cron    *cron.Cron
+	jobs    map[string]*job
+	states  map[string]*state
+	started bool
+	mutex   sync.Mutex
+
+	// now returns the current time, and is replaced in tests
+	now func() time.Time
+}
+
+// New creates a scheduler persisting the status of its jobs in the given store
+func New(st *store.Store) (*Scheduler, error) {
+	s := &Scheduler{
+		store:  st,
+		cron:   cron.New(),
+		jobs:   map[string]*job{},
+		states: map[string]*state{},
+		now:    time.Now,
+	}
+
+	err := st.Load(storeName, &s.states)
+	if err != nil {
+		return nil, fmt.Errorf("loading job states: %v", err)
+	}
+
+	return s, nil
+}
+
+// Add registers the job. Jobs added after the scheduler has started are caught up on immediately
+func (s *Scheduler) Add(j Job) error {
+	if j.Name == "" || j.Schedule == nil || j.Run == nil {
+		return errors.New("jobs need a name, schedule and function to run")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.jobs[j.Name]; exists {
+		return fmt.Errorf("a job named %q already exists", j.Name)
+	}
+
+	name := j.Name
+	s.jobs[name] = &job{
+		Job:     j,
+		entryID: s.cron.Schedule(j.Schedule, cron.FuncJob(func() { s.runScheduled(name) })),
+	}
+
+	if _, exists := s.states[name]; !exists {
+		// jobs are not caught up on runs from before they were first registered
+		s.states[name] = &state{Since: s.now()}
+		s.save()
+	}
+
+	if s.started {
+		if missed := s.missedRun(name, s.now()); !missed.IsZero() {
+			logrus.Infof("catching up on job %q missed at %s", name, missed)
+			go s.run(name)
+		}
+	}
+
+	return nil
+}
+
+// Remove unregisters the job with the given name and forgets its status
+func (s *Scheduler) Remove(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	j, exists := s.jobs[name]
+	if !exists {
+		return
+	}
+
+	s.cron.Remove(j.entryID)
+	delete(s.jobs, name)
+	delete(s.states, name)
+	s.save()
+}
+
+// Start starts running the jobs, first catching up on the ones missed while the bot was down in
+// the order they were missed
+func (s *Scheduler) Start() {
+	s.mutex.Lock()
+	now := s.now()
+	missed := map[string]time.Time{}
+	names := []string{}
+	for name := range s.jobs {
+		if at := s.missedRun(name, now); !at.IsZero() {
+			missed[name] = at
+			names = append(names, name)
+		}
+	}
+	s.started = true
+	s.cron.Start()
+	s.mutex.Unlock()
+
+	sort.Slice(names, func(i, j int) bool { return missed[names[i]].Before(missed[names[j]]) })
+
+	go func() {
+		for _, name := range names {
+			logrus.Infof("catching up on job %q missed at %s", name, missed[name])
+			s.run(name)
+		}
+	}()
+}
+
+// Stop stops running jobs. Jobs already running are not interrupted
+func (s *Scheduler) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.started {
+		s.cron.Stop()
+		s.started = false
+	}
+}
+
+// Run runs the job with the given name now, returning its error
+func (s *Scheduler) Run(name string) error {
+	s.mutex.Lock()
+	_, exists := s.jobs[name]
+	s.mutex.Unlock()
+	if !exists {
+		return fmt.Errorf("found no job named %q", name)
+	}
+
+	return s.run(name)
+}
+
+// Pause stops the job with the given name from running on its schedule until resumed
+func (s *Scheduler) Pause(name string) error {
+	return s.setPaused(name, true)
+}
+
+// Resume makes a paused job run on its schedule again. Runs missed while paused are not caught up
+func (s *Scheduler) Resume(name string) error {
+	return s.setPaused(name, false)
+}
+
+// Jobs returns the status of the registered jobs, ordered by name
+func (s *Scheduler) Jobs() []Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	statuses := []Status{}
+	for name, j := range s.jobs {
+		st := s.states[name]
+		statuses = append(statuses, Status{
+			Name:        name,
+			Description: j.Description,
+			Paused:      st.Paused,
+			LastRun:     st.LastRun,
+			NextRun:     j.Schedule.Next(now),
+			LastError:   st.LastError,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func (s *Scheduler) setPaused(name string, paused bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.jobs[name]; !exists {
+		return fmt.Errorf("found no job named %q", name)
+	}
+
+	st := s.states[name]
+	st.Paused = paused
+	st.Since = s.now()
+	s.save()
+	return nil
+}
+
+func (s *Scheduler) runScheduled(name string) {
+	s.mutex.Lock()
+	st, exists := s.states[name]
+	paused := exists && st.Paused
+	if paused {
+		st.Since = s.now()
+		s.save()
+	}
+	s.mutex.Unlock()
+
+	if !exists || paused {
+		return
+	}
+
+	err := s.run(name)
+	if err != nil {
+		logrus.Errorf("error occurred running job %q: %v", name, err)
+	}
+}
+
+// run runs the job and records its status. A job is never run twice at the same time
+func (s *Scheduler) run(name string) error {
+	s.mutex.Lock()
+	j, exists := s.jobs[name]
+	if !exists {
+		s.mutex.Unlock()
+		return nil
+	}
+	if j.running {
+		s.mutex.Unlock()
+		return fmt.Errorf("job %q is already running", name)
+	}
+	j.running = true
+	s.mutex.Unlock()
+
+	err := j.Run()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	j.running = false
+	st, exists := s.states[name]
+	if !exists {
+		// the job removed itself
+		return err
+	}
+
+	st.Since = s.now()
+	st.LastRun = st.Since
+	st.LastError = ""
+	if err != nil {
+		st.LastError = err.Error()
+	}
+	s.save()
+
+	return err
+}
+
+// missedRun returns the last time the job was due between when it last ran and now, or the zero
+// time if it was not missed
+func (s *Scheduler) missedRun(name string, now time.Time) (missed time.Time) {
+	j := s.jobs[name]
+	st := s.states[name]
+	if !j.CatchUp || st.Paused {
+		return
+	}
+
+	for i, next := 0, j.Schedule.Next(st.Since); i < maxMissedRuns; i++ {
+		if next.IsZero() || next.After(now) {
+			break
+		}
+		missed = next
+		next = j.Schedule.Next(next)
+	}
+
+	return
+}
+
+// save persists the job states, and must be called with the mutex held
+func (s *Scheduler) save() {
+	err := s.store.Save(storeName, s.states)
+	if err != nil {
+		logrus.Errorf("error occurred saving job states: %v", err)
+	}
+}
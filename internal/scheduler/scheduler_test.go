@@ -0,0 +1,218 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/polarbirds/lunde/internal/store"
+	"gopkg.in/robfig/cron.v2"
+)
+
+// clock is a time set by the tests
+type clock struct {
+	t     time.Time
+	mutex sync.Mutex
+}
+
+func (c *clock) now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.t
+}
+
+func (c *clock) set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.t = t
+}
+
+func newTestScheduler(t *testing.T, now time.Time) (*Scheduler, *clock) {
+	s, err := New(store.New(t.TempDir()))
+	if err != nil {
+		t.Fatalf("creating scheduler: %v", err)
+	}
+
+	c := &clock{t: now}
+	s.now = c.now
+	return s, c
+}
+
+func daily(t *testing.T) cron.Schedule {
+	sch, err := cron.Parse("0 0 8 * * *")
+	if err != nil {
+		t.Fatalf("parsing schedule: %v", err)
+	}
+	return sch
+}
+
+func TestMissedRun(t *testing.T) {
+	now := time.Date(2024, time.March, 12, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule cron.Schedule
+		catchUp  bool
+		state    state
+		want     time.Time
+	}{
+		{
+			name:    "missed today",
+			catchUp: true,
+			state:   state{Since: now.Add(-24 * time.Hour)},
+			want:    time.Date(2024, time.March, 12, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "last of several missed",
+			catchUp: true,
+			state:   state{Since: now.AddDate(0, 0, -10)},
+			want:    time.Date(2024, time.March, 12, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "ran since due",
+			catchUp: true,
+			state:   state{Since: time.Date(2024, time.March, 12, 9, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "not caught up on",
+			state: state{Since: now.Add(-24 * time.Hour)},
+		},
+		{
+			name:    "paused",
+			catchUp: true,
+			state:   state{Since: now.Add(-24 * time.Hour), Paused: true},
+		},
+		{
+			name:     "once in the past",
+			schedule: Once(now.Add(-time.Hour)),
+			catchUp:  true,
+			state:    state{Since: now.Add(-2 * time.Hour)},
+			want:     now.Add(-time.Hour),
+		},
+		{
+			name:     "once in the future",
+			schedule: Once(now.Add(time.Hour)),
+			catchUp:  true,
+			state:    state{Since: now.Add(-2 * time.Hour)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sch := tt.schedule
+			if sch == nil {
+				sch = daily(t)
+			}
+
+			st := tt.state
+			s := &Scheduler{
+				jobs:   map[string]*job{"job": {Job: Job{Schedule: sch, CatchUp: tt.catchUp}}},
+				states: map[string]*state{"job": &st},
+			}
+
+			got := s.missedRun("job", now)
+			if !got.Equal(tt.want) {
+				t.Errorf("got missed run %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartCatchesUp(t *testing.T) {
+	now := time.Date(2024, time.March, 12, 12, 0, 0, 0, time.UTC)
+	s, _ := newTestScheduler(t, now)
+	defer s.Stop()
+
+	ran := make(chan string, 3)
+	for _, name := range []string{"missed", "not caught up", "ran"} {
+		name := name
+		since := now.Add(-24 * time.Hour)
+		if name == "ran" {
+			since = now.Add(-time.Hour)
+		}
+		s.states[name] = &state{Since: since}
+
+		err := s.Add(Job{
+			Name:     name,
+			Schedule: daily(t),
+			Run:      func() error { ran <- name; return nil },
+			CatchUp:  name != "not caught up",
+		})
+		if err != nil {
+			t.Fatalf("adding job: %v", err)
+		}
+	}
+
+	s.Start()
+
+	select {
+	case name := <-ran:
+		if name != "missed" {
+			t.Fatalf("got job %q run on start, want %q", name, "missed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("missed job not run on start")
+	}
+
+	select {
+	case name := <-ran:
+		t.Errorf("got job %q run on start, want only the missed job", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for _, status := range s.Jobs() {
+		if status.Name == "missed" && !status.LastRun.Equal(now) {
+			t.Errorf("got last run %s, want %s", status.LastRun, now)
+		}
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	now := time.Date(2024, time.March, 12, 7, 0, 0, 0, time.UTC)
+	s, c := newTestScheduler(t, now)
+
+	runs := 0
+	err := s.Add(Job{
+		Name:     "job",
+		Schedule: daily(t),
+		Run:      func() error { runs++; return nil },
+		CatchUp:  true,
+	})
+	if err != nil {
+		t.Fatalf("adding job: %v", err)
+	}
+
+	err = s.Pause("job")
+	if err != nil {
+		t.Fatalf("pausing job: %v", err)
+	}
+
+	c.set(now.Add(2 * time.Hour))
+	s.runScheduled("job")
+	if runs != 0 {
+		t.Errorf("got %d runs of a paused job, want 0", runs)
+	}
+	if missed := s.missedRun("job", c.now()); !missed.IsZero() {
+		t.Errorf("got missed run %s of a paused job, want none", missed)
+	}
+
+	c.set(now.Add(26 * time.Hour))
+	err = s.Resume("job")
+	if err != nil {
+		t.Fatalf("resuming job: %v", err)
+	}
+	if missed := s.missedRun("job", c.now()); !missed.IsZero() {
+		t.Errorf("got missed run %s after resuming, want runs missed while paused skipped",
+			missed)
+	}
+
+	s.runScheduled("job")
+	if runs != 1 {
+		t.Errorf("got %d runs of a resumed job, want 1", runs)
+	}
+
+	err = s.Pause("unknown")
+	if err == nil {
+		t.Error("got no error pausing an unknown job")
+	}
+}
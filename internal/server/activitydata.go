@@ -0,0 +1,74 @@
+package server
+
+import (
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// activityRetention is how long messages are kept for activity statistics
+const activityRetention = 8 * 24 * time.Hour
+
+// ActivityRecord is a message sent in the guild, kept for activityRetention
+type ActivityRecord struct {
+	MessageID discord.MessageID
+	ChannelID discord.ChannelID
+	AuthorID  discord.UserID
+	Time      time.Time
+	Content   string
+}
+
+func (srv *Server) buildActivityMessages(messages []discord.Message) {
+	cutoff := time.Now().Add(-activityRetention)
+
+	srv.ActivityMutex.Lock()
+	defer srv.ActivityMutex.Unlock()
+
+	if srv.ActivityData == nil {
+		srv.ActivityData = make(map[discord.MessageID]ActivityRecord)
+	}
+
+	for _, msg := range messages {
+		if msg.Author.Bot || msg.Timestamp.Time().Before(cutoff) {
+			continue
+		}
+
+		// keyed by message, as messages sent while building data are also fetched from history
+		srv.ActivityData[msg.ID] = ActivityRecord{
+			MessageID: msg.ID,
+			ChannelID: msg.ChannelID,
+			AuthorID:  msg.Author.ID,
+			Time:      msg.Timestamp.Time(),
+			Content:   msg.Content,
+		}
+	}
+}
+
+// Activity returns the messages sent since the given time, at most activityRetention ago
+func (srv *Server) Activity(since time.Time) []ActivityRecord {
+	srv.ActivityMutex.RLock()
+	defer srv.ActivityMutex.RUnlock()
+
+	records := []ActivityRecord{}
+	for _, rec := range srv.ActivityData {
+		if !rec.Time.Before(since) {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// pruneActivityPeriodically forgets messages older than activityRetention every hour
+func (srv *Server) pruneActivityPeriodically() {
+	for range time.Tick(time.Hour) {
+		cutoff := time.Now().Add(-activityRetention)
+
+		srv.ActivityMutex.Lock()
+		for id, rec := range srv.ActivityData {
+			if rec.Time.Before(cutoff) {
+				delete(srv.ActivityData, id)
+			}
+		}
+		srv.ActivityMutex.Unlock()
+	}
+}
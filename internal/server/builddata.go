@@ -32,10 +32,25 @@ func (srv *Server) buildData() {
 	}
 
 	wg.Wait()
-	srv.BuildingDataDone = true
+	close(srv.dataBuilt)
 	logrus.Infof("done building data, took %s", time.Since(startTime))
 }
 
+// DataBuilt returns a channel closed when the data has been built from the message history
+func (srv *Server) DataBuilt() <-chan struct{} {
+	return srv.dataBuilt
+}
+
+// IsDataBuilt returns whether the data has been built from the message history
+func (srv *Server) IsDataBuilt() bool {
+	select {
+	case <-srv.dataBuilt:
+		return true
+	default:
+		return false
+	}
+}
+
 func (srv *Server) buildDataForChannel(ch discord.Channel) {
 	switch ch.Type {
 	case discord.GuildText, discord.GroupDM, discord.DirectMessage:
@@ -56,4 +71,5 @@ func (srv *Server) buildDataForChannel(ch discord.Channel) {
 
 func (srv *Server) buildDataFromMessages(messages []discord.Message) {
 	srv.buildCountMessages(messages)
+	srv.buildActivityMessages(messages)
 }
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/sirupsen/logrus"
+)
+
+const reactionDataStoreName = "reactions"
+
+// reactionRetention is how long reactions are kept for reaction statistics
+const reactionRetention = 365 * 24 * time.Hour
+
+// ReactionRecord is a reaction given by a user to a message
+type ReactionRecord struct {
+	MessageID discord.MessageID `json:"messageID"`
+	ChannelID discord.ChannelID `json:"channelID"`
+	// AuthorID is the author of the message, the receiver of the reaction
+	AuthorID discord.UserID `json:"authorID"`
+	// UserID is the giver of the reaction
+	UserID discord.UserID   `json:"userID"`
+	Emoji  discord.APIEmoji `json:"emoji"`
+	Time   time.Time        `json:"time"`
+}
+
+func (srv *Server) loadReactionData() error {
+	srv.ReactionMutex.Lock()
+	defer srv.ReactionMutex.Unlock()
+
+	// stored as a list, as the index by message only serves lookups
+	records := []ReactionRecord{}
+	err := srv.Store.Load(reactionDataStoreName, &records)
+	if err != nil {
+		return fmt.Errorf("loading reaction data: %v", err)
+	}
+
+	srv.ReactionData = make(map[discord.MessageID][]ReactionRecord)
+	for _, rec := range records {
+		srv.ReactionData[rec.MessageID] = append(srv.ReactionData[rec.MessageID], rec)
+	}
+
+	return nil
+}
+
+// SaveReactionData saves the reaction data if it has changed since it was last saved
+func (srv *Server) SaveReactionData() error {
+	srv.ReactionMutex.Lock()
+	defer srv.ReactionMutex.Unlock()
+
+	if !srv.reactionDataChanged {
+		return nil
+	}
+
+	records := []ReactionRecord{}
+	for _, messageRecords := range srv.ReactionData {
+		records = append(records, messageRecords...)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+
+	err := srv.Store.Save(reactionDataStoreName, records)
+	if err != nil {
+		return fmt.Errorf("saving reaction data: %v", err)
+	}
+
+	srv.reactionDataChanged = false
+	return nil
+}
+
+// saveReactionDataPeriodically saves the reaction data every minute if it has changed, instead of
+// rewriting it on every single reaction
+func (srv *Server) saveReactionDataPeriodically() {
+	for range time.Tick(time.Minute) {
+		err := srv.SaveReactionData()
+		if err != nil {
+			logrus.Errorf("error occurred saving reaction data: %v", err)
+		}
+	}
+}
+
+// pruneReactionsPeriodically forgets reactions older than reactionRetention every hour
+func (srv *Server) pruneReactionsPeriodically() {
+	for range time.Tick(time.Hour) {
+		cutoff := time.Now().Add(-reactionRetention)
+
+		srv.ReactionMutex.Lock()
+		for id, records := range srv.ReactionData {
+			kept := records[:0]
+			for _, rec := range records {
+				if !rec.Time.Before(cutoff) {
+					kept = append(kept, rec)
+				}
+			}
+
+			if len(kept) == len(records) {
+				continue
+			}
+			if len(kept) == 0 {
+				delete(srv.ReactionData, id)
+			} else {
+				srv.ReactionData[id] = kept
+			}
+			srv.reactionDataChanged = true
+		}
+		srv.ReactionMutex.Unlock()
+	}
+}
+
+func (srv *Server) recordReactionAdd(ev *gateway.MessageReactionAddEvent) {
+	if ev.Member != nil && ev.Member.User.Bot {
+		return
+	}
+
+	authorID, err := srv.messageAuthor(ev.ChannelID, ev.MessageID)
+	if err != nil {
+		logrus.Errorf("error occurred recording reaction: %v", err)
+		return
+	}
+
+	srv.ReactionMutex.Lock()
+	srv.ReactionData[ev.MessageID] = append(srv.ReactionData[ev.MessageID], ReactionRecord{
+		MessageID: ev.MessageID,
+		ChannelID: ev.ChannelID,
+		AuthorID:  authorID,
+		UserID:    ev.UserID,
+		Emoji:     ev.Emoji.APIString(),
+		Time:      time.Now(),
+	})
+	srv.reactionDataChanged = true
+	srv.ReactionMutex.Unlock()
+}
+
+func (srv *Server) recordReactionRemove(ev *gateway.MessageReactionRemoveEvent) {
+	srv.ReactionMutex.Lock()
+	defer srv.ReactionMutex.Unlock()
+
+	records := srv.ReactionData[ev.MessageID]
+	for i, rec := range records {
+		if rec.UserID == ev.UserID && rec.Emoji == ev.Emoji.APIString() {
+			records = append(records[:i], records[i+1:]...)
+			if len(records) == 0 {
+				delete(srv.ReactionData, ev.MessageID)
+			} else {
+				srv.ReactionData[ev.MessageID] = records
+			}
+			srv.reactionDataChanged = true
+			return
+		}
+	}
+}
+
+// recordReactionRemoveEmoji forgets the reactions with the emoji to the message, or all of its
+// reactions if emoji is empty, as when a moderator clears them
+func (srv *Server) recordReactionRemoveEmoji(messageID discord.MessageID, emoji discord.APIEmoji) {
+	srv.ReactionMutex.Lock()
+	defer srv.ReactionMutex.Unlock()
+
+	records, exists := srv.ReactionData[messageID]
+	if !exists {
+		return
+	}
+
+	kept := []ReactionRecord{}
+	for _, rec := range records {
+		if emoji != "" && rec.Emoji != emoji {
+			kept = append(kept, rec)
+		}
+	}
+
+	if len(kept) == len(records) {
+		return
+	}
+	if len(kept) == 0 {
+		delete(srv.ReactionData, messageID)
+	} else {
+		srv.ReactionData[messageID] = kept
+	}
+	srv.reactionDataChanged = true
+}
+
+// messageAuthor returns the author of the given message, from an earlier reaction to the message
+// if there is one, to avoid fetching the message for every reaction
+func (srv *Server) messageAuthor(channelID discord.ChannelID, messageID discord.MessageID) (
+	discord.UserID, error,
+) {
+	srv.ReactionMutex.RLock()
+	var authorID discord.UserID
+	if records := srv.ReactionData[messageID]; len(records) > 0 {
+		authorID = records[0].AuthorID
+	}
+	srv.ReactionMutex.RUnlock()
+	if authorID.IsValid() {
+		return authorID, nil
+	}
+
+	msg, err := srv.Session.Message(channelID, messageID)
+	if err != nil {
+		return 0, fmt.Errorf("getting message %s: %v", messageID, err)
+	}
+
+	return msg.Author.ID, nil
+}
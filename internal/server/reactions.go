@@ -5,7 +5,57 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// AddReactionAddHandler registers a function to be called every time a reaction is added to a
+// message
+func (srv *Server) AddReactionAddHandler(handler func(*gateway.MessageReactionAddEvent)) {
+	srv.handlerMutex.Lock()
+	srv.reactionAddHandlers = append(srv.reactionAddHandlers, handler)
+	srv.handlerMutex.Unlock()
+}
+
+// AddReactionRemoveHandler registers a function to be called every time a reaction is removed
+// from a message
+func (srv *Server) AddReactionRemoveHandler(handler func(*gateway.MessageReactionRemoveEvent)) {
+	srv.handlerMutex.Lock()
+	srv.reactionRemoveHandlers = append(srv.reactionRemoveHandlers, handler)
+	srv.handlerMutex.Unlock()
+}
+
 // HandleReactionAddInteraction handles when reactions are added to messages
-func (*Server) HandleReactionAddInteraction(ev *gateway.MessageReactionAddEvent) {
+func (srv *Server) HandleReactionAddInteraction(ev *gateway.MessageReactionAddEvent) {
 	logrus.Infof("reaction was added by user %q to message %+v", ev.Member.Nick, ev)
+	srv.recordReactionAdd(ev)
+
+	srv.handlerMutex.RLock()
+	defer srv.handlerMutex.RUnlock()
+	for _, handler := range srv.reactionAddHandlers {
+		handler(ev)
+	}
+}
+
+// HandleReactionRemoveInteraction handles when reactions are removed from messages
+func (srv *Server) HandleReactionRemoveInteraction(ev *gateway.MessageReactionRemoveEvent) {
+	logrus.Infof("reaction was removed by user %s from message %+v", ev.UserID, ev)
+	srv.recordReactionRemove(ev)
+
+	srv.handlerMutex.RLock()
+	defer srv.handlerMutex.RUnlock()
+	for _, handler := range srv.reactionRemoveHandlers {
+		handler(ev)
+	}
+}
+
+// HandleReactionRemoveAllInteraction handles when all reactions are removed from a message
+func (srv *Server) HandleReactionRemoveAllInteraction(ev *gateway.MessageReactionRemoveAllEvent) {
+	logrus.Infof("all reactions were removed from message %s", ev.MessageID)
+	srv.recordReactionRemoveEmoji(ev.MessageID, "")
+}
+
+// HandleReactionRemoveEmojiInteraction handles when all reactions with an emoji are removed from a
+// message
+func (srv *Server) HandleReactionRemoveEmojiInteraction(
+	ev *gateway.MessageReactionRemoveEmojiEvent,
+) {
+	logrus.Infof("reactions with %s were removed from message %s", ev.Emoji, ev.MessageID)
+	srv.recordReactionRemoveEmoji(ev.MessageID, ev.Emoji.APIString())
 }
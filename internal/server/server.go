@@ -2,9 +2,9 @@ package server
 
 import (
 	"fmt"
-	"math/rand"
-	"regexp"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
@@ -12,6 +12,8 @@ import (
 	"github.com/diamondburned/arikawa/v3/session"
 	"github.com/haraldfw/cfger"
 	"github.com/polarbirds/lunde/internal/command"
+	"github.com/polarbirds/lunde/internal/scheduler"
+	"github.com/polarbirds/lunde/internal/store"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/go-playground/validator.v9"
 )
@@ -19,8 +21,6 @@ import (
 // CreateCommand is a function that returns a LundeCommand
 type CreateCommand func(*Server) (command.LundeCommand, error)
 
-var nicePattern = regexp.MustCompile(`(^|\D)69(\D|$)`)
-
 // Server is the config and main server
 type Server struct {
 	Token            string            `yaml:"token" validate:"required"`
@@ -30,8 +30,25 @@ type Server struct {
 
 	MessagesToGetForDataBuild uint `yaml:"messagesToGetForDataBuild"`
 
+	// Timezone is the IANA timezone schedules and times given by users are in, defaults to the
+	// local timezone
+	Timezone string `yaml:"timezone"`
+	Location *time.Location
+
+	// DataDir is where state that needs to survive restarts is kept
+	DataDir string `yaml:"dataDir"`
+	Store   *store.Store
+
+	// Scheduler runs the scheduled jobs registered by commands and features
+	Scheduler *scheduler.Scheduler
+
 	commands map[string]command.LundeCommand
 
+	messageCreateHandlers  []func(*gateway.MessageCreateEvent)
+	reactionAddHandlers    []func(*gateway.MessageReactionAddEvent)
+	reactionRemoveHandlers []func(*gateway.MessageReactionRemoveEvent)
+	handlerMutex           sync.RWMutex
+
 	Session               *session.Session
 	LastMessages          map[discord.ChannelID]*gateway.MessageCreateEvent
 	lastMessageWriteMutex sync.Mutex
@@ -39,13 +56,22 @@ type Server struct {
 	CountData  map[discord.UserID]map[string]int
 	CountMutex sync.RWMutex
 
-	BuildingDataDone bool
+	ReactionData        map[discord.MessageID][]ReactionRecord
+	ReactionMutex       sync.RWMutex
+	reactionDataChanged bool
+
+	ActivityData  map[discord.MessageID]ActivityRecord
+	ActivityMutex sync.RWMutex
+
+	// dataBuilt is closed when the data has been built from the message history
+	dataBuilt chan struct{}
 }
 
 // New creates a new server instance with initialized variables
 func New() (srv Server, err error) {
 	srv = Server{
 		LastMessages: make(map[discord.ChannelID]*gateway.MessageCreateEvent),
+		dataBuilt:    make(chan struct{}),
 	}
 
 	_, err = cfger.ReadStructuredCfgRecursive("env::CONFIG", &srv)
@@ -60,6 +86,30 @@ func New() (srv Server, err error) {
 		return
 	}
 
+	srv.Location = time.Local
+	if srv.Timezone != "" {
+		srv.Location, err = time.LoadLocation(srv.Timezone)
+		if err != nil {
+			err = fmt.Errorf("loading timezone %q: %v", srv.Timezone, err)
+			return
+		}
+	}
+
+	if srv.DataDir == "" {
+		srv.DataDir = "data"
+	}
+	srv.Store = store.New(srv.DataDir)
+
+	srv.Scheduler, err = scheduler.New(srv.Store)
+	if err != nil {
+		return
+	}
+
+	err = srv.loadReactionData()
+	if err != nil {
+		return
+	}
+
 	srv.commands = map[string]command.LundeCommand{}
 
 	return
@@ -90,28 +140,35 @@ func (srv *Server) Initialize(s *session.Session, commandCreators []CreateComman
 	}
 
 	go srv.buildData()
+	go srv.saveReactionDataPeriodically()
+	go srv.pruneReactionsPeriodically()
+	go srv.pruneActivityPeriodically()
+
+	// started after the commands have registered their jobs, so all of them are caught up on
+	srv.Scheduler.Start()
 
 	srv.commands = cmdMap
 	return nil
 }
 
+// AddMessageCreateHandler registers a function to be called for every incoming normal message
+func (srv *Server) AddMessageCreateHandler(handler func(*gateway.MessageCreateEvent)) {
+	srv.handlerMutex.Lock()
+	srv.messageCreateHandlers = append(srv.messageCreateHandlers, handler)
+	srv.handlerMutex.Unlock()
+}
+
 // HandleMessageCreate handles every incoming normal message
 func (srv *Server) HandleMessageCreate(c *gateway.MessageCreateEvent) {
 	srv.lastMessageWriteMutex.Lock()
 	srv.LastMessages[c.ChannelID] = c
 	srv.lastMessageWriteMutex.Unlock()
 
-	if nicePattern.Match([]byte(c.Content)) {
-		var emojiAPIString discord.APIEmoji = "nice:536833842078810112"
-		if rand.Intn(2) == 1 {
-			emojiAPIString = "♋"
-		}
-		err := srv.Session.React(c.ChannelID, c.ID, emojiAPIString)
-		if err != nil {
-			logrus.Errorf("error occurred adding reaction: %v", err)
-			return
-		}
+	srv.handlerMutex.RLock()
+	for _, handler := range srv.messageCreateHandlers {
+		handler(c)
 	}
+	srv.handlerMutex.RUnlock()
 
 	if c.ChannelID == srv.BacklogChannelID {
 		err := srv.Session.React(c.ChannelID, c.ID, "🗑")
@@ -126,12 +183,13 @@ func (srv *Server) HandleMessageCreate(c *gateway.MessageCreateEvent) {
 
 // HandleInteraction is a handler-function handling interaction-events
 func (srv *Server) HandleInteraction(ev *gateway.InteractionCreateEvent) {
-	// revive:disable-next-line:unnecessary-stmt
-	switch ev.Data.(type) {
+	switch data := ev.Data.(type) {
 	case *discord.CommandInteraction:
-		data := ev.Data.(*discord.CommandInteraction)
 		srv.handleCommandInteraction(ev, data)
-		return
+	case discord.ComponentInteraction:
+		srv.handleComponentInteraction(ev, data)
+	case *discord.AutocompleteInteraction:
+		srv.handleAutocompleteInteraction(ev, data)
 	}
 }
 
@@ -161,25 +219,71 @@ func (srv *Server) handleCommandInteraction(
 
 	responseData, err := cmd.HandleInteraction(event, options)
 	if err != nil {
-		log.Warnf("error occurred handling interaction: %v", err)
-		dm, dmErr := srv.Session.CreatePrivateChannel(event.Member.User.ID)
-		if dmErr != nil {
-			log.Errorf("error occurred creating private channel to report error: %v", dmErr)
-			return
-		}
+		srv.reportInteractionError(event, log, err)
+		return
+	}
 
-		_, dmErr = srv.Session.SendMessage(dm.ID, err.Error())
-		if dmErr != nil {
-			log.Errorf("error occurred sending DM to report error: %v", dmErr)
-			return
-		}
+	srv.respond(event, log, api.MessageInteractionWithSource, responseData)
+}
+
+// handleComponentInteraction routes interactions with message components to the command whose name
+// prefixes the custom ID of the component
+func (srv *Server) handleComponentInteraction(
+	event *gateway.InteractionCreateEvent,
+	data discord.ComponentInteraction,
+) {
+	log := logrus.WithField("component", data.ID())
+
+	parts := strings.SplitN(string(data.ID()), command.ComponentIDSeparator, 2)
+	cmd, exists := srv.commands[parts[0]]
+	if !exists || cmd.HandleComponent == nil || len(parts) < 2 {
+		log.Errorf("no command handles component %s", data.ID())
+		return
+	}
 
+	responseData, err := cmd.HandleComponent(event, parts[1])
+	if err != nil {
+		srv.reportInteractionError(event, log, err)
 		return
 	}
 
+	srv.respond(event, log, api.UpdateMessage, responseData)
+}
+
+// handleAutocompleteInteraction responds with the suggestions of the command for the option being
+// typed. Errors are only logged, as they would otherwise be reported for every keystroke
+func (srv *Server) handleAutocompleteInteraction(
+	event *gateway.InteractionCreateEvent,
+	data *discord.AutocompleteInteraction,
+) {
+	log := logrus.WithField("autocomplete", data.Name)
+
+	cmd, exists := srv.commands[data.Name]
+	if !exists || cmd.HandleAutocomplete == nil {
+		log.Errorf("command %s does not autocomplete", data.Name)
+		return
+	}
+
+	choices, err := cmd.HandleAutocomplete(event, data.Options)
+	if err != nil {
+		log.Warnf("error occurred autocompleting: %v", err)
+	}
+	if choices == nil {
+		choices = api.AutocompleteStringChoices{}
+	}
+
+	srv.respond(event, log, api.AutocompleteResult, &api.InteractionResponseData{Choices: choices})
+}
+
+func (srv *Server) respond(
+	event *gateway.InteractionCreateEvent,
+	log *logrus.Entry,
+	responseType api.InteractionResponseType,
+	data *api.InteractionResponseData,
+) {
 	interactionResp := api.InteractionResponse{
-		Type: api.MessageInteractionWithSource,
-		Data: responseData,
+		Type: responseType,
+		Data: data,
 	}
 	if err := srv.Session.RespondInteraction(event.ID, event.Token, interactionResp); err != nil {
 		log.Errorf("failed to send interaction callback: %v", err)
@@ -189,6 +293,24 @@ func (srv *Server) handleCommandInteraction(
 	log.Infof("responded to interaction")
 }
 
+// reportInteractionError sends the error from handling an interaction to the user in a DM
+func (srv *Server) reportInteractionError(
+	event *gateway.InteractionCreateEvent, log *logrus.Entry, err error,
+) {
+	log.Warnf("error occurred handling interaction: %v", err)
+	dm, dmErr := srv.Session.CreatePrivateChannel(event.Member.User.ID)
+	if dmErr != nil {
+		log.Errorf("error occurred creating private channel to report error: %v", dmErr)
+		return
+	}
+
+	_, dmErr = srv.Session.SendMessage(dm.ID, err.Error())
+	if dmErr != nil {
+		log.Errorf("error occurred sending DM to report error: %v", dmErr)
+		return
+	}
+}
+
 func opsToMap(ops discord.CommandInteractionOptions) (
 	opMap map[string]discord.CommandInteractionOption,
 	err error,
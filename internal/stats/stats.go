@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/polarbirds/lunde/internal/server"
+)
+
+// topCount is how many entries the top lists have
+const topCount = 5
+
+type countedMessage struct {
+	record server.ReactionRecord
+	count  int
+}
+
+type counted struct {
+	key   string
+	count int
+}
+
+// MostReacted lists links to the messages with the most reactions given since the given time
+func MostReacted(srv *server.Server, since time.Time) string {
+	messages := map[discord.MessageID]*countedMessage{}
+
+	srv.ReactionMutex.RLock()
+	for messageID, records := range srv.ReactionData {
+		for _, rec := range records {
+			if rec.Time.Before(since) {
+				continue
+			}
+
+			if _, exists := messages[messageID]; !exists {
+				messages[messageID] = &countedMessage{record: rec}
+			}
+			messages[messageID].count++
+		}
+	}
+	srv.ReactionMutex.RUnlock()
+
+	if len(messages) == 0 {
+		return "no reactions found"
+	}
+
+	topMessages := make([]*countedMessage, 0, len(messages))
+	for _, m := range messages {
+		topMessages = append(topMessages, m)
+	}
+	sort.Slice(topMessages, func(i, j int) bool {
+		return topMessages[i].count > topMessages[j].count
+	})
+	if len(topMessages) > topCount {
+		topMessages = topMessages[:topCount]
+	}
+
+	messageLines := make([]string, len(topMessages))
+	for i, m := range topMessages {
+		messageLines[i] = fmt.Sprintf("%d. [message](https://discord.com/channels/%s/%s/%s) "+
+			"by %s: %d", i+1, srv.GuildID, m.record.ChannelID, m.record.MessageID,
+			m.record.AuthorID.Mention(), m.count)
+	}
+
+	return strings.Join(messageLines, "\n")
+}
+
+// TopLines lists the keys with the highest counts with their counts, highest first
+func TopLines(counts map[string]int) string {
+	sorted := make([]counted, 0, len(counts))
+	for k, c := range counts {
+		sorted = append(sorted, counted{key: k, count: c})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].count > sorted[j].count
+	})
+	if len(sorted) > topCount {
+		sorted = sorted[:topCount]
+	}
+
+	lines := make([]string, len(sorted))
+	for i, c := range sorted {
+		lines[i] = fmt.Sprintf("%d. %s: %d", i+1, c.key, c.count)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store persists named values as JSON-files in a directory, so that state survives restarts
+type Store struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+// New creates a store keeping its files in the given directory
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Load reads the value stored under the given name into v. If nothing has been stored under the
+// name yet, v is left untouched and no error is returned
+func (s *Store) Load(name string, v interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bodBytes, err := ioutil.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading %q: %v", name, err)
+	}
+
+	err = json.Unmarshal(bodBytes, v)
+	if err != nil {
+		return fmt.Errorf("decoding %q: %v", name, err)
+	}
+
+	return nil
+}
+
+// Save stores v under the given name, replacing what was previously stored
+func (s *Store) Save(name string, v interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bodBytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding %q: %v", name, err)
+	}
+
+	err = os.MkdirAll(s.dir, 0o755)
+	if err != nil {
+		return fmt.Errorf("creating data directory %q: %v", s.dir, err)
+	}
+
+	// write to a temporary file first so a crash never leaves a half-written file behind
+	tmpPath := s.path(name) + ".tmp"
+	err = ioutil.WriteFile(tmpPath, bodBytes, 0o644)
+	if err != nil {
+		return fmt.Errorf("writing %q: %v", name, err)
+	}
+
+	err = os.Rename(tmpPath, s.path(name))
+	if err != nil {
+		return fmt.Errorf("replacing %q: %v", name, err)
+	}
+
+	return nil
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
@@ -0,0 +1,33 @@
+package tmplfuncs
+
+import (
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/polarbirds/lunde/internal/calendar"
+)
+
+// Funcs are the functions available to all templates written in the config or by users
+var Funcs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// WithDaysUntil returns Funcs along with daysUntil, which returns the days from now until the
+// given date on the form 2006-01-02 in loc
+func WithDaysUntil(loc *time.Location, now func() time.Time) template.FuncMap {
+	funcs := template.FuncMap{
+		"daysUntil": func(date string) (int, error) {
+			t, err := time.ParseInLocation("2006-01-02", date, loc)
+			if err != nil {
+				return 0, err
+			}
+			return calendar.DaysBetween(now().In(loc), t), nil
+		},
+	}
+	for name, f := range Funcs {
+		funcs[name] = f
+	}
+	return funcs
+}
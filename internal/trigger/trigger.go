@@ -0,0 +1,150 @@
+package trigger
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// Trigger decides whether a message should trigger an automatic action, by matching its content
+// against a regex or a list of words, limited to some channels, a probability and a cooldown
+type Trigger struct {
+	// Pattern is a regular expression matched against the message content
+	Pattern string `yaml:"pattern" json:"pattern,omitempty"`
+	// Words are matched case-insensitively against the whole words of the message content
+	Words []string `yaml:"words" json:"words,omitempty"`
+	// Channels limits the trigger to the given channels, all channels if empty
+	Channels []discord.ChannelID `yaml:"channels" json:"channels,omitempty"`
+	// Probability is the chance of firing when matched, between 0 and 1. 0 means always
+	Probability float64 `yaml:"probability" json:"probability,omitempty"`
+	// Cooldown is the minimum time between firings in the same channel
+	Cooldown time.Duration `yaml:"cooldown" json:"cooldown,omitempty"`
+
+	compiled  *regexp.Regexp
+	lastFired map[discord.ChannelID]time.Time
+	mutex     sync.Mutex
+}
+
+// Compile validates the trigger and prepares it for matching
+func (t *Trigger) Compile() (err error) {
+	if t.Pattern == "" && len(t.Words) == 0 {
+		return errors.New("either a pattern or words must be given")
+	}
+
+	if t.Probability < 0 || t.Probability > 1 {
+		return fmt.Errorf("probability %v is not between 0 and 1", t.Probability)
+	}
+
+	if t.Pattern != "" {
+		t.compiled, err = regexp.Compile(t.Pattern)
+		if err != nil {
+			return fmt.Errorf("compiling pattern %q: %v", t.Pattern, err)
+		}
+	}
+
+	t.lastFired = make(map[discord.ChannelID]time.Time)
+	return nil
+}
+
+// Fire returns whether a message with the given content in the given channel fires the trigger,
+// along with the match and capture groups of the pattern, or the matched word. Firing starts the
+// cooldown of the trigger in the channel
+func (t *Trigger) Fire(channelID discord.ChannelID, content string) (
+	captures []string, fired bool,
+) {
+	if !t.inChannel(channelID) {
+		return nil, false
+	}
+
+	captures = t.match(content)
+	if captures == nil {
+		return nil, false
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if time.Since(t.lastFired[channelID]) < t.Cooldown {
+		return nil, false
+	}
+
+	if t.Probability != 0 && rand.Float64() >= t.Probability {
+		return nil, false
+	}
+
+	t.lastFired[channelID] = time.Now()
+	return captures, true
+}
+
+// Describe returns a short human readable description of what the trigger matches
+func (t *Trigger) Describe() string {
+	parts := []string{}
+	if t.Pattern != "" {
+		parts = append(parts, fmt.Sprintf("pattern `%s`", t.Pattern))
+	}
+	if len(t.Words) > 0 {
+		parts = append(parts, fmt.Sprintf("words `%s`", strings.Join(t.Words, ", ")))
+	}
+	if len(t.Channels) > 0 {
+		channels := make([]string, len(t.Channels))
+		for i, ch := range t.Channels {
+			channels[i] = ch.Mention()
+		}
+		parts = append(parts, "in "+strings.Join(channels, " "))
+	}
+	if t.Probability != 0 {
+		parts = append(parts, fmt.Sprintf("%.0f%% of the time", t.Probability*100))
+	}
+	if t.Cooldown != 0 {
+		parts = append(parts, fmt.Sprintf("cooldown %s", t.Cooldown))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func (t *Trigger) inChannel(channelID discord.ChannelID) bool {
+	if len(t.Channels) == 0 {
+		return true
+	}
+
+	for _, ch := range t.Channels {
+		if ch == channelID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Trigger) match(content string) []string {
+	if t.compiled != nil {
+		if captures := t.compiled.FindStringSubmatch(content); captures != nil {
+			return captures
+		}
+	}
+
+	if len(t.Words) == 0 {
+		return nil
+	}
+
+	// split on anything but letters and digits, so words with æøå are matched whole
+	contentWords := strings.FieldsFunc(content, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, contentWord := range contentWords {
+		for _, word := range t.Words {
+			if strings.EqualFold(contentWord, word) {
+				return []string{contentWord}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package when
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHour is the hour of times given as only a day, like "tomorrow" or "friday"
+const defaultHour = 9
+
+var (
+	clockPattern    = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+	isoDatePattern  = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})$`)
+	dottedDate      = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})\.?(\d{4})?$`)
+	durationPattern = regexp.MustCompile(`^(\d+)\s*([a-zæøå]+)$`)
+)
+
+var units = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "second": time.Second, "seconds": time.Second,
+	"sekund": time.Second, "sekunder": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute,
+	"minutes": time.Minute, "minutt": time.Minute, "minutter": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"t": time.Hour, "time": time.Hour, "timer": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"dag": 24 * time.Hour, "dager": 24 * time.Hour, "døgn": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+	"uke": 7 * 24 * time.Hour, "uker": 7 * 24 * time.Hour,
+}
+
+var weekdays = map[string]time.Weekday{
+	"monday": time.Monday, "mandag": time.Monday,
+	"tuesday": time.Tuesday, "tirsdag": time.Tuesday,
+	"wednesday": time.Wednesday, "onsdag": time.Wednesday,
+	"thursday": time.Thursday, "torsdag": time.Thursday,
+	"friday": time.Friday, "fredag": time.Friday,
+	"saturday": time.Saturday, "lørdag": time.Saturday,
+	"sunday": time.Sunday, "søndag": time.Sunday,
+}
+
+// Parse parses a time written the way people do, relative to now and in its location. It accepts
+// durations like "in 2h" or "in 1 day 3 hours", days like "today", "tomorrow", "friday",
+// "2024-12-24" or "24.12", clock times like "18:00", and days followed by clock times like
+// "tomorrow 09:00". Norwegian words are accepted too. Days without a clock time mean 09:00, and
+// weekdays and clock times without a day mean their next occurrence
+func Parse(text string, now time.Time) (time.Time, error) {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	if text == "" {
+		return time.Time{}, errors.New("no time given")
+	}
+
+	for _, prefix := range []string{"in ", "om "} {
+		if strings.HasPrefix(text, prefix) {
+			d, err := parseDuration(strings.TrimPrefix(text, prefix))
+			if err != nil {
+				return time.Time{}, err
+			}
+			return now.Add(d), nil
+		}
+	}
+
+	if d, err := parseDuration(text); err == nil {
+		return now.Add(d), nil
+	}
+
+	words := strings.Fields(text)
+	clock := ""
+	if len(words) > 1 && clockPattern.MatchString(words[len(words)-1]) {
+		clock = words[len(words)-1]
+		text = strings.Join(words[:len(words)-1], " ")
+	} else if clockPattern.MatchString(text) {
+		clock = text
+		text = ""
+	}
+
+	hour, minute := defaultHour, 0
+	if clock != "" {
+		var err error
+		hour, minute, err = parseClock(clock)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if text == "" {
+		t := at(now, hour, minute)
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	day, err := parseDay(text, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t := at(day, hour, minute)
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("%s is in the past", t.Format("2006-01-02 15:04"))
+	}
+	return t, nil
+}
+
+// parseDuration parses durations like "2h", "1h30m", "2 hours" and "1 day and 3 hours"
+func parseDuration(text string) (time.Duration, error) {
+	if d, err := time.ParseDuration(strings.ReplaceAll(text, " ", "")); err == nil {
+		if d <= 0 {
+			return 0, fmt.Errorf("%q is not in the future", text)
+		}
+		return d, nil
+	}
+
+	text = strings.NewReplacer(",", " ", " and ", " ", " og ", " ").Replace(text)
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return 0, errors.New("no duration given")
+	}
+
+	var total time.Duration
+	for i := 0; i < len(words); i++ {
+		part := words[i]
+		// allow a space between the amount and the unit
+		if _, err := strconv.Atoi(part); err == nil && i+1 < len(words) {
+			part += words[i+1]
+			i++
+		}
+
+		match := durationPattern.FindStringSubmatch(part)
+		if match == nil {
+			return 0, fmt.Errorf("%q is not a duration", text)
+		}
+
+		unit, exists := units[match[2]]
+		if !exists {
+			return 0, fmt.Errorf("unknown unit %q", match[2])
+		}
+
+		amount, err := strconv.Atoi(match[1])
+		if err != nil {
+			return 0, fmt.Errorf("parsing %q: %v", match[1], err)
+		}
+		total += time.Duration(amount) * unit
+	}
+
+	if total <= 0 {
+		return 0, fmt.Errorf("%q is not in the future", text)
+	}
+	return total, nil
+}
+
+func parseClock(text string) (hour, minute int, err error) {
+	match := clockPattern.FindStringSubmatch(text)
+	if match == nil {
+		err = fmt.Errorf("%q is not a clock time", text)
+		return
+	}
+
+	hour, _ = strconv.Atoi(match[1])
+	minute, _ = strconv.Atoi(match[2])
+	if hour > 23 || minute > 59 {
+		err = fmt.Errorf("%q is not a clock time", text)
+	}
+	return
+}
+
+// parseDay parses the day part of a time, returning midnight of the day
+func parseDay(text string, now time.Time) (time.Time, error) {
+	today := at(now, 0, 0)
+
+	switch text {
+	case "today", "i dag", "idag":
+		return today, nil
+	case "tomorrow", "i morgen", "imorgen":
+		return today.AddDate(0, 0, 1), nil
+	}
+
+	text = strings.TrimPrefix(text, "next ")
+	text = strings.TrimPrefix(text, "på ")
+	if weekday, exists := weekdays[text]; exists {
+		days := (int(weekday) - int(now.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, days), nil
+	}
+
+	if match := isoDatePattern.FindStringSubmatch(text); match != nil {
+		year, _ := strconv.Atoi(match[1])
+		month, _ := strconv.Atoi(match[2])
+		day, _ := strconv.Atoi(match[3])
+		return date(year, month, day, now.Location())
+	}
+
+	if match := dottedDate.FindStringSubmatch(text); match != nil {
+		day, _ := strconv.Atoi(match[1])
+		month, _ := strconv.Atoi(match[2])
+		year := now.Year()
+		if match[3] != "" {
+			year, _ = strconv.Atoi(match[3])
+		}
+
+		t, err := date(year, month, day, now.Location())
+		// dates without a year mean their next occurrence
+		if err == nil && match[3] == "" && t.Before(today) {
+			t = t.AddDate(1, 0, 0)
+		}
+		return t, err
+	}
+
+	return time.Time{}, fmt.Errorf("could not understand %q as a time", text)
+}
+
+func date(year, month, day int, loc *time.Location) (time.Time, error) {
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
+	if t.Day() != day || int(t.Month()) != month {
+		return time.Time{}, fmt.Errorf("%04d-%02d-%02d is not a date", year, month, day)
+	}
+	return t, nil
+}
+
+func at(day time.Time, hour, minute int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+}
@@ -0,0 +1,89 @@
+package when
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, oslo)
+	}
+
+	// a tuesday
+	now := at(2024, time.March, 12, 14, 30)
+	// the saturday before summer time starts at 02:00 on sunday
+	beforeDST := at(2024, time.March, 30, 12, 0)
+
+	tests := []struct {
+		text    string
+		now     time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		// relative offsets
+		{text: "in 2h", now: now, want: at(2024, time.March, 12, 16, 30)},
+		{text: "1h30m", now: now, want: at(2024, time.March, 12, 16, 0)},
+		{text: "in 2 hours 15 minutes", now: now, want: at(2024, time.March, 12, 16, 45)},
+		{text: "om 1 dag og 3 timer", now: now, want: at(2024, time.March, 13, 17, 30)},
+		{text: "in 1 week", now: now, want: at(2024, time.March, 19, 14, 30)},
+		{text: "in 0h", now: now, wantErr: true},
+		{text: "in 3 fortnights", now: now, wantErr: true},
+
+		// weekdays
+		{text: "friday", now: now, want: at(2024, time.March, 15, 9, 0)},
+		{text: "next friday", now: now, want: at(2024, time.March, 15, 9, 0)},
+		{text: "tuesday", now: now, want: at(2024, time.March, 19, 9, 0)},
+		{text: "på fredag 18:00", now: now, want: at(2024, time.March, 15, 18, 0)},
+		{text: "Monday", now: now, want: at(2024, time.March, 18, 9, 0)},
+
+		// days and clock times
+		{text: "18:00", now: now, want: at(2024, time.March, 12, 18, 0)},
+		{text: "tomorrow", now: now, want: at(2024, time.March, 13, 9, 0)},
+		{text: "tomorrow 09:00", now: now, want: at(2024, time.March, 13, 9, 0)},
+		{text: "i dag 20:00", now: now, want: at(2024, time.March, 12, 20, 0)},
+		{text: "2024-12-24 18:00", now: now, want: at(2024, time.December, 24, 18, 0)},
+		{text: "24.12", now: now, want: at(2024, time.December, 24, 9, 0)},
+		{text: "24.12.2025", now: now, want: at(2025, time.December, 24, 9, 0)},
+		{text: "25:00", now: now, wantErr: true},
+		{text: "2024-02-30", now: now, wantErr: true},
+		{text: "someday", now: now, wantErr: true},
+		{text: "  ", now: now, wantErr: true},
+
+		// past times
+		{text: "09:00", now: now, want: at(2024, time.March, 13, 9, 0)},
+		{text: "14:30", now: now, want: at(2024, time.March, 13, 14, 30)},
+		{text: "1.3", now: now, want: at(2025, time.March, 1, 9, 0)},
+		{text: "today 10:00", now: now, wantErr: true},
+		{text: "2024-01-01", now: now, wantErr: true},
+
+		// across the change to summer time
+		{text: "in 24h", now: beforeDST, want: at(2024, time.March, 31, 13, 0)},
+		{text: "tomorrow 12:00", now: beforeDST, want: at(2024, time.March, 31, 12, 0)},
+		{text: "sunday", now: beforeDST, want: at(2024, time.March, 31, 9, 0)},
+		{text: "11:00", now: beforeDST, want: at(2024, time.March, 31, 11, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := Parse(tt.text, tt.now)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %s, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if !got.Equal(tt.want) || got.Location() != oslo {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}